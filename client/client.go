@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"time"
+
+	"github.com/vderic/kite-client-go/internal/bufpool"
 )
 
 var KITE_MESSAGE_KIT1 = [4]byte{'K', 'I', 'T', '1'}
@@ -18,14 +21,36 @@ type KiteMessage struct {
 	Buffer []byte
 }
 
+const KITE_READ_BUFFER_SIZE = 64 * 1024
+
+// KITE_CANCEL_TIMEOUT bounds the time Cancel spends sending the cancellation.
+const KITE_CANCEL_TIMEOUT = time.Second
+
 type SockStream struct {
 	Conn net.Conn
+
+	// state of ReadAvailable
+	rbuf   []byte
+	meta   [12]byte
+	nmeta  int
+	msg    KiteMessage
+	nbody  int
+	inbody bool
 }
 
 func (sock *SockStream) Close() {
 	sock.Conn.Close()
 }
 
+// Cancel asks the server to stop the request by sending BYE_ and closes the
+// connection. It may be called while another goroutine reads the stream.
+func (sock *SockStream) Cancel() error {
+	sock.Conn.SetWriteDeadline(time.Now().Add(KITE_CANCEL_TIMEOUT))
+	err := sock.Send(KITE_MESSAGE_BYE, nil)
+	sock.Close()
+	return err
+}
+
 func (sock *SockStream) readfully(msg []byte, msgsz int) error {
 	var err error = nil
 	p := 0
@@ -82,22 +107,118 @@ func (sock *SockStream) Send(msgty [4]byte, msg []byte) error {
 }
 
 func (sock *SockStream) Recv() (msg KiteMessage, err error) {
+	return sock.recv(func(n int) []byte { return make([]byte, n) })
+}
+
+// RecvPooled is Recv with the message buffer taken from a pool. The buffer
+// must be given back with Free once nothing refers to it anymore.
+func (sock *SockStream) RecvPooled() (msg KiteMessage, err error) {
+	return sock.recv(bufpool.Get)
+}
+
+// Free returns the buffer of a message received by RecvPooled to the pool.
+func (msg *KiteMessage) Free() {
+	bufpool.Put(msg.Buffer)
+	msg.Buffer = nil
+}
+
+// parseMeta returns the type and length of a message from its 12 byte
+// header.
+func parseMeta(meta []byte) (msgty [4]byte, msglen int64, err error) {
+	copy(msgty[:], meta[0:4])
+	msglen, err = strconv.ParseInt(string(meta[4:]), 16, 32)
+	if err != nil {
+		return
+	}
+	if msglen < 0 {
+		err = fmt.Errorf("invalid message length %d", msglen)
+	}
+	return
+}
+
+func (sock *SockStream) recv(alloc func(int) []byte) (msg KiteMessage, err error) {
 	meta := make([]byte, 12)
 	err = sock.readfully(meta, len(meta))
 	if err != nil {
 		return
 	}
 
-	var msgty [4]byte
-	copy(msgty[:], meta[0:4])
-	msglen, err := strconv.ParseInt(string(meta[4:]), 16, 32)
+	msgty, msglen, err := parseMeta(meta)
 	if err != nil {
 		return
 	}
 
-	msg = KiteMessage{msgty, int32(msglen), make([]byte, msglen)}
+	msg = KiteMessage{msgty, int32(msglen), alloc(int(msglen))}
 	if msglen > 0 {
-		sock.readfully(msg.Buffer, int(msglen))
+		err = sock.readfully(msg.Buffer, int(msglen))
+		if err != nil {
+			msg.Free()
+		}
 	}
 	return
 }
+
+// ReadAvailable performs a single Read on the connection and returns the
+// messages it completed. A partially received message is kept until the
+// following calls, so a connection can be serviced whenever the poller
+// reports it readable without blocking until a whole message arrives.
+// With pooled, message buffers come from the pool as in RecvPooled.
+func (sock *SockStream) ReadAvailable(pooled bool) (msgs []KiteMessage, err error) {
+	alloc := func(n int) []byte { return make([]byte, n) }
+	if pooled {
+		alloc = bufpool.Get
+	}
+
+	// read large message bodies in place instead of through rbuf
+	if sock.inbody && len(sock.msg.Buffer)-sock.nbody >= KITE_READ_BUFFER_SIZE {
+		n, err := sock.Conn.Read(sock.msg.Buffer[sock.nbody:])
+		sock.nbody += n
+		if sock.nbody == len(sock.msg.Buffer) {
+			msgs = append(msgs, sock.msg)
+			sock.msg = KiteMessage{}
+			sock.inbody = false
+		}
+		return msgs, err
+	}
+
+	if sock.rbuf == nil {
+		sock.rbuf = make([]byte, KITE_READ_BUFFER_SIZE)
+	}
+	n, err := sock.Conn.Read(sock.rbuf)
+	b := sock.rbuf[:n]
+	for len(b) > 0 {
+		if !sock.inbody {
+			c := copy(sock.meta[sock.nmeta:], b)
+			sock.nmeta += c
+			b = b[c:]
+			if sock.nmeta < len(sock.meta) {
+				break
+			}
+
+			msgty, msglen, perr := parseMeta(sock.meta[:])
+			if perr != nil {
+				return msgs, perr
+			}
+			sock.nmeta = 0
+			sock.msg = KiteMessage{msgty, int32(msglen), alloc(int(msglen))}
+			sock.nbody = 0
+			sock.inbody = true
+		}
+
+		c := copy(sock.msg.Buffer[sock.nbody:], b)
+		sock.nbody += c
+		b = b[c:]
+		if sock.nbody == len(sock.msg.Buffer) {
+			msgs = append(msgs, sock.msg)
+			sock.msg = KiteMessage{}
+			sock.inbody = false
+		}
+	}
+	return msgs, err
+}
+
+// Pending reports whether part of a message has been read by
+// ReadAvailable.
+func (sock *SockStream) Pending() bool {
+	return sock.inbody || sock.nmeta > 0
+}
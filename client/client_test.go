@@ -0,0 +1,63 @@
+package client
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// serve writes b on one end of a pipe and returns the other end.
+func serve(b []byte) net.Conn {
+	server, conn := net.Pipe()
+	go func() {
+		server.Write(b)
+		server.Close()
+	}()
+	return conn
+}
+
+func TestRecv(t *testing.T) {
+	b := []byte("VEC_00000003abcBYE_00000000")
+
+	ss := SockStream{Conn: serve(b)}
+	msg, err := ss.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Msgty != KITE_MESSAGE_VECTOR || !bytes.Equal(msg.Buffer, []byte("abc")) {
+		t.Errorf("got %s %q", msg.Msgty[:], msg.Buffer)
+	}
+	msg, err = ss.Recv()
+	if err != nil || msg.Msgty != KITE_MESSAGE_BYE {
+		t.Errorf("got %s, %v, want BYE_", msg.Msgty[:], err)
+	}
+
+	ss = SockStream{Conn: serve(b)}
+	var msgs []KiteMessage
+	for len(msgs) < 2 {
+		m, err := ss.ReadAvailable(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m...)
+	}
+	if len(msgs) != 2 || !bytes.Equal(msgs[0].Buffer, []byte("abc")) || msgs[1].Msgty != KITE_MESSAGE_BYE {
+		t.Errorf("ReadAvailable got %v", msgs)
+	}
+}
+
+func TestRecvBadLength(t *testing.T) {
+	for _, meta := range []string{"VEC_-0000001", "VEC_-7FFFFFF", "VEC_zzzzzzzz"} {
+		ss := SockStream{Conn: serve([]byte(meta))}
+		_, err := ss.Recv()
+		if err == nil {
+			t.Errorf("Recv %s: no error", meta)
+		}
+
+		ss = SockStream{Conn: serve([]byte(meta))}
+		_, err = ss.ReadAvailable(true)
+		if err == nil {
+			t.Errorf("ReadAvailable %s: no error", meta)
+		}
+	}
+}
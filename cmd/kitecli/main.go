@@ -0,0 +1,210 @@
+// kitecli runs a query against kite and prints the result as an aligned
+// table, CSV or JSON lines.
+//
+//	kitecli -host localhost:7878 -schema test/data/gpdb0.schema -fragcnt 3 \
+//	    -sql 'select * from "tmp/gpdb/gpdb*.parquet"' -o table
+//
+// Without -sql it starts an interactive shell, see \? in the shell for the
+// meta commands.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vderic/kite-client-go"
+)
+
+type config struct {
+	hosts   []string
+	sql     string
+	fragid  int
+	fragcnt int
+	fragids []int
+	sample  int
+	seed    int64
+	schema  []kite.Coldef
+	spec    kite.FileSpec
+	output  string
+}
+
+func loadSchema(path string) ([]kite.Coldef, error) {
+	bv, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema []kite.Coldef
+	err = json.Unmarshal(bv, &schema)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return schema, nil
+}
+
+func newFileSpec(format, delim, quote, escape, nullstr string, header bool) (kite.FileSpec, error) {
+	switch format {
+	case "csv":
+		return kite.NewCsvFileSpec(delim, quote, escape, nullstr, header), nil
+	case "parquet":
+		return kite.NewParquetFileSpec(), nil
+	}
+	return nil, fmt.Errorf("unknown file format %s", format)
+}
+
+func splitFragids(fragids string) ([]int, error) {
+	var res []int
+	for _, s := range strings.Split(fragids, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fragment id %s", s)
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+func splitHosts(hosts string) []string {
+	var res []string
+	for _, h := range strings.Split(hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" {
+			res = append(res, h)
+		}
+	}
+	return res
+}
+
+// run submits the query and writes every row to w. It returns the number of
+// rows written.
+func run(cfg *config, w rowWriter) (int, error) {
+	cli := kite.NewKiteClient()
+	cli.Schema(cfg.schema).Sql(cfg.sql).Fragment(cfg.fragid, cfg.fragcnt).FileSpec(cfg.spec).Host(cfg.hosts)
+	if cfg.sample > 0 {
+		cli.Sample(cfg.sample, cfg.fragcnt, cfg.seed)
+	} else if cfg.fragids != nil {
+		cli.Fragments(cfg.fragids, cfg.fragcnt)
+	}
+	err := cli.Submit()
+	if err != nil {
+		return 0, err
+	}
+	defer cli.Close()
+
+	// ^C cancels the query on the servers
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, os.Interrupt)
+	defer func() {
+		signal.Stop(sig)
+		close(done)
+	}()
+	go func() {
+		select {
+		case <-sig:
+			cli.Cancel()
+		case <-done:
+		}
+	}()
+
+	n := 0
+	for {
+		it, err := cli.NextRow()
+		if err != nil {
+			return n, err
+		}
+		if it == nil {
+			break
+		}
+
+		if n == 0 {
+			err = w.Header(it.Columns())
+			if err != nil {
+				return n, err
+			}
+		}
+		err = w.Row(it)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, w.Flush()
+}
+
+func main() {
+	hosts := flag.String("host", "localhost:7878", "comma separated list of kite hosts")
+	sql := flag.String("sql", "", "SQL statement")
+	fragid := flag.Int("fragid", -1, "fragment id, -1 for all fragments")
+	fragcnt := flag.Int("fragcnt", 1, "fragment count")
+	fragids := flag.String("fragids", "", "comma separated list of fragment ids to run")
+	sample := flag.Int("sample", 0, "run this many fragments chosen at random")
+	seed := flag.Int64("seed", 0, "random seed for -sample, 0 for a different sample every run")
+	schema := flag.String("schema", "", "schema file, a JSON list of {name, type, precision, scale}")
+	format := flag.String("fmt", "parquet", "file format, csv or parquet")
+	delim := flag.String("delim", ",", "csv delimiter")
+	quote := flag.String("quote", "\"", "csv quote character")
+	escape := flag.String("escape", "\"", "csv escape character")
+	nullstr := flag.String("nullstr", "", "csv NULL string")
+	header := flag.Bool("header", false, "csv files have a header line")
+	output := flag.String("o", "table", "output format, table, csv or json")
+	flag.Parse()
+
+	var err error
+	cfg := config{hosts: splitHosts(*hosts), sql: *sql, fragid: *fragid, fragcnt: *fragcnt, sample: *sample, seed: *seed, output: *output}
+	if cfg.sample > 0 && cfg.seed == 0 {
+		cfg.seed = time.Now().UnixNano()
+	}
+	if *fragids != "" {
+		cfg.fragids, err = splitFragids(*fragids)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *schema != "" {
+		cfg.schema, err = loadSchema(*schema)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	cfg.spec, err = newFileSpec(*format, *delim, *quote, *escape, *nullstr, *header)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.sql == "" {
+		err = runShell(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	w, err := newRowWriter(cfg.output, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	n, err := run(&cfg, w)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "(%d rows) in %v\n", n, time.Since(start))
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+type rowWriter interface {
+	Header(columns []string) error
+	Row(it *xrg.Iterator) error
+	Flush() error
+}
+
+func newRowWriter(format string, w io.Writer) (rowWriter, error) {
+	switch format {
+	case "table":
+		return &tableWriter{w: w}, nil
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case "json":
+		return &jsonWriter{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %s", format)
+}
+
+func isNull(it *xrg.Iterator, i int) bool {
+	return it.Flag[i]&xrg.XRG_FLAG_NULL != 0
+}
+
+func formatColumn(it *xrg.Iterator, i int) string {
+	hdr := &it.Header[i]
+	return xrg.FormatValue(it.Value[i], hdr.Ltyp, hdr.Precision, hdr.Scale)
+}
+
+// columnNames falls back to positional names when the columns are unnamed.
+func columnNames(columns []string, nvec int) []string {
+	if len(columns) == nvec {
+		return columns
+	}
+	names := make([]string, nvec)
+	for i := range names {
+		names[i] = fmt.Sprintf("col%d", i+1)
+	}
+	return names
+}
+
+// tableWriter buffers all rows to align the columns, psql style.
+type tableWriter struct {
+	w       io.Writer
+	columns []string
+	rows    [][]string
+}
+
+func (t *tableWriter) Header(columns []string) error {
+	t.columns = columns
+	return nil
+}
+
+func (t *tableWriter) Row(it *xrg.Iterator) error {
+	if len(t.columns) != it.Nvec {
+		t.columns = columnNames(t.columns, it.Nvec)
+	}
+	row := make([]string, it.Nvec)
+	for i := range row {
+		if isNull(it, i) {
+			row[i] = ""
+		} else {
+			row[i] = formatColumn(it, i)
+		}
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+func (t *tableWriter) Flush() error {
+	if len(t.columns) == 0 {
+		return nil
+	}
+
+	widths := make([]int, len(t.columns))
+	for i, c := range t.columns {
+		widths[i] = utf8.RuneCountInString(c)
+	}
+	for _, row := range t.rows {
+		for i, v := range row {
+			if n := utf8.RuneCountInString(v); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(t.w)
+	writeLine := func(cells []string) {
+		for i, c := range cells {
+			if i > 0 {
+				bw.WriteString(" | ")
+			}
+			bw.WriteString(c)
+			if i < len(cells)-1 {
+				bw.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c)))
+			}
+		}
+		bw.WriteByte('\n')
+	}
+
+	writeLine(t.columns)
+	for i, w := range widths {
+		if i > 0 {
+			bw.WriteString("-+-")
+		}
+		bw.WriteString(strings.Repeat("-", w))
+	}
+	bw.WriteByte('\n')
+	for _, row := range t.rows {
+		writeLine(row)
+	}
+	t.rows = nil
+	return bw.Flush()
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Header(columns []string) error {
+	return nil
+}
+
+func (c *csvWriter) Row(it *xrg.Iterator) error {
+	row := make([]string, it.Nvec)
+	for i := range row {
+		if !isNull(it, i) {
+			row[i] = formatColumn(it, i)
+		}
+	}
+	return c.w.Write(row)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonWriter writes one JSON object per row, keeping the column order.
+type jsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func (j *jsonWriter) Header(columns []string) error {
+	j.columns = columns
+	return nil
+}
+
+func (j *jsonWriter) Row(it *xrg.Iterator) error {
+	if len(j.columns) != it.Nvec {
+		j.columns = columnNames(j.columns, it.Nvec)
+	}
+
+	j.w.WriteByte('{')
+	for i := 0; i < it.Nvec; i++ {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(j.columns[i])
+		j.w.Write(key)
+		j.w.WriteByte(':')
+
+		var v any
+		if !isNull(it, i) {
+			hdr := &it.Header[i]
+			v = jsonValue(it.Value[i], hdr.Ltyp, hdr.Precision, hdr.Scale)
+		}
+		js, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		j.w.Write(js)
+	}
+	_, err := j.w.WriteString("}\n")
+	return err
+}
+
+func (j *jsonWriter) Flush() error {
+	return j.w.Flush()
+}
+
+// jsonValue keeps numbers as JSON numbers and renders everything else as
+// postgres text. Arrays become (nested) JSON arrays.
+func jsonValue(v any, ltyp xrg.LogicalType, precision int16, scale int16) any {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case byte:
+		return int8(x)
+	case int16:
+		return x
+	case int32:
+		if ltyp == xrg.XRG_LTYP_NONE {
+			return x
+		}
+	case int64:
+		if ltyp == xrg.XRG_LTYP_NONE {
+			return x
+		}
+	case float32:
+		if !math.IsNaN(float64(x)) && !math.IsInf(float64(x), 0) {
+			return x
+		}
+	case float64:
+		if !math.IsNaN(x) && !math.IsInf(x, 0) {
+			return x
+		}
+	case xrg.ArrayType:
+		return jsonArray(&x, x.Nested())
+	}
+	return xrg.FormatValue(v, ltyp, precision, scale)
+}
+
+func jsonArray(arr *xrg.ArrayType, values []any) []any {
+	res := make([]any, len(values))
+	for i, v := range values {
+		if nested, ok := v.([]any); ok {
+			res[i] = jsonArray(arr, nested)
+		} else {
+			res[i] = jsonValue(v, arr.Header.Ltyp, arr.Precision, arr.Scale)
+		}
+	}
+	return res
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+	"github.com/vderic/kite-client-go"
+)
+
+const shellHelp = `SQL statements end with ';' and may span several lines.
+
+  \host HOST[,HOST...]      set the kite hosts
+  \fragment [FRAGID] CNT    set the fragment id (-1 for all) and count
+  \filespec parquet         read parquet files
+  \filespec csv [delim=,] [quote="] [escape="] [nullstr=] [header=false]
+                            read csv files
+  \schema [FILE]            load a schema file or show the current schema
+  \o table|csv|json         set the output format
+  \timing [on|off]          show the time taken by each query
+  \pager [on|off]           page long results through $PAGER
+  \r                        reset the query buffer
+  \?                        show this help
+  \q                        quit
+`
+
+type shell struct {
+	cfg    config
+	timing bool
+	pager  bool
+	line   *liner.State
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kite_history")
+}
+
+func runShell(cfg config) error {
+	sh := &shell{cfg: cfg, pager: true}
+	sh.line = liner.NewLiner()
+	defer sh.line.Close()
+	sh.line.SetCtrlCAborts(true)
+
+	hist := historyPath()
+	if f, err := os.Open(hist); err == nil {
+		sh.line.ReadHistory(f)
+		f.Close()
+	}
+	defer func() {
+		if f, err := os.Create(hist); err == nil {
+			sh.line.WriteHistory(f)
+			f.Close()
+		}
+	}()
+
+	fmt.Println("kite shell. Type \\? for help.")
+	var buf []string
+	for {
+		prompt := "kite> "
+		if len(buf) > 0 {
+			prompt = "kite-> "
+		}
+
+		input, err := sh.line.Prompt(prompt)
+		if err == liner.ErrPromptAborted {
+			buf = nil
+			continue
+		}
+		if err == io.EOF {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+
+		if len(buf) == 0 && strings.HasPrefix(input, "\\") {
+			sh.line.AppendHistory(input)
+			quit, err := sh.meta(input)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+
+		buf = append(buf, input)
+		if !strings.HasSuffix(input, ";") {
+			continue
+		}
+
+		sql := strings.Join(buf, "\n")
+		buf = nil
+		sh.line.AppendHistory(sql)
+		err = sh.query(strings.TrimSuffix(sql, ";"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+func (sh *shell) meta(input string) (quit bool, err error) {
+	args := strings.Fields(input)
+	cmd, args := args[0], args[1:]
+
+	switch cmd {
+	case "\\q":
+		return true, nil
+	case "\\?":
+		fmt.Print(shellHelp)
+	case "\\r":
+		// the buffer is always empty when a meta command is entered
+	case "\\host":
+		if len(args) != 1 {
+			return false, errors.New("usage: \\host HOST[,HOST...]")
+		}
+		sh.cfg.hosts = splitHosts(args[0])
+	case "\\fragment":
+		return false, sh.setFragment(args)
+	case "\\filespec":
+		return false, sh.setFileSpec(args)
+	case "\\schema":
+		if len(args) == 0 {
+			sh.showSchema()
+			return false, nil
+		}
+		schema, err := loadSchema(args[0])
+		if err != nil {
+			return false, err
+		}
+		sh.cfg.schema = schema
+	case "\\o":
+		if len(args) != 1 {
+			return false, errors.New("usage: \\o table|csv|json")
+		}
+		if _, err := newRowWriter(args[0], io.Discard); err != nil {
+			return false, err
+		}
+		sh.cfg.output = args[0]
+	case "\\timing":
+		sh.timing, err = toggle(sh.timing, args)
+		fmt.Println("Timing is", onOff(sh.timing))
+	case "\\pager":
+		sh.pager, err = toggle(sh.pager, args)
+		fmt.Println("Pager is", onOff(sh.pager))
+	default:
+		return false, fmt.Errorf("unknown command %s, try \\?", cmd)
+	}
+	return false, err
+}
+
+func toggle(v bool, args []string) (bool, error) {
+	if len(args) == 0 {
+		return !v, nil
+	}
+	switch args[0] {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return v, fmt.Errorf("expected on or off, got %s", args[0])
+}
+
+func onOff(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
+
+func (sh *shell) setFragment(args []string) error {
+	var err error
+	fragid, fragcnt := -1, 0
+	switch len(args) {
+	case 1:
+		fragcnt, err = strconv.Atoi(args[0])
+	case 2:
+		fragid, err = strconv.Atoi(args[0])
+		if err == nil {
+			fragcnt, err = strconv.Atoi(args[1])
+		}
+	default:
+		return errors.New("usage: \\fragment [FRAGID] CNT")
+	}
+	if err != nil {
+		return err
+	}
+	sh.cfg.fragid, sh.cfg.fragcnt = fragid, fragcnt
+	return nil
+}
+
+func (sh *shell) setFileSpec(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: \\filespec csv|parquet [key=value...]")
+	}
+
+	opts := map[string]string{"delim": ",", "quote": "\"", "escape": "\"", "nullstr": "", "header": "false"}
+	for _, arg := range args[1:] {
+		k, v, ok := strings.Cut(arg, "=")
+		if _, known := opts[k]; !ok || !known {
+			return fmt.Errorf("invalid csv option %s", arg)
+		}
+		opts[k] = v
+	}
+	header, err := strconv.ParseBool(opts["header"])
+	if err != nil {
+		return err
+	}
+
+	spec, err := newFileSpec(args[0], opts["delim"], opts["quote"], opts["escape"], opts["nullstr"], header)
+	if err != nil {
+		return err
+	}
+	sh.cfg.spec = spec
+	return nil
+}
+
+func (sh *shell) showSchema() {
+	if len(sh.cfg.schema) == 0 {
+		fmt.Println("no schema loaded")
+		return
+	}
+	for _, col := range sh.cfg.schema {
+		if col.Precision != 0 || col.Scale != 0 {
+			fmt.Printf("%s %s(%d,%d)\n", col.Name, col.Type, col.Precision, col.Scale)
+		} else {
+			fmt.Printf("%s %s\n", col.Name, col.Type)
+		}
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// pagerCmd starts $PAGER (less by default) reading from the returned writer.
+func pagerCmd() (*exec.Cmd, io.WriteCloser, error) {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less -SFX"
+	}
+	args := strings.Fields(pager)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, nil, err
+	}
+	return cmd, w, nil
+}
+
+func (sh *shell) query(sql string) error {
+	cfg := sh.cfg
+	cfg.sql = sql
+	if cfg.spec == nil {
+		cfg.spec = kite.NewParquetFileSpec()
+	}
+
+	var out io.Writer = os.Stdout
+	var pager *exec.Cmd
+	var pipe io.WriteCloser
+	if sh.pager && isTerminal(os.Stdout) {
+		var err error
+		pager, pipe, err = pagerCmd()
+		if err != nil {
+			return err
+		}
+		out = pipe
+	}
+
+	w, err := newRowWriter(cfg.output, out)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	n, err := run(&cfg, w)
+	elapsed := time.Since(start)
+	if pager != nil {
+		pipe.Close()
+		pager.Wait()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("(%d rows)\n", n)
+	if sh.timing {
+		fmt.Printf("Time: %.3f ms\n", float64(elapsed.Microseconds())/1000)
+	}
+	return nil
+}
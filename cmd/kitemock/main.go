@@ -0,0 +1,55 @@
+// kitemock serves the vectors of an XRG file to every kite request, for
+// trying out kitecli and other clients without a kite cluster.
+//
+//	kitemock -addr localhost:7878 -file test/data/gpdb0_0.xrg
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/vderic/kite-client-go/kitetest"
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:7878", "listen address, host:port or unix:///path/to/socket")
+	file := flag.String("file", "", "XRG file to serve")
+	delay := flag.Duration("delay", 0, "delay before sending each page")
+	keepalive := flag.Bool("keepalive", false, "serve more than one request per connection")
+	flag.Parse()
+
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "no XRG file provided")
+		os.Exit(2)
+	}
+
+	f, err := xrg.ReadFile(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	pages, err := kitetest.PagesFromFile(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	srv := kitetest.NewUnstartedServer(pages)
+	srv.PageDelay = *delay
+	srv.KeepAlive = *keepalive
+	err = srv.Start(*addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "serving", *file, "on", srv.Addr)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+	srv.Close()
+	fmt.Fprintf(os.Stderr, "%d requests served\n", len(srv.Requests()))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vderic/kite-client-go"
+)
+
+type csvFlags struct {
+	delim   *string
+	quote   *string
+	escape  *string
+	nullstr *string
+	header  *bool
+	schema  *string
+}
+
+func addCsvFlags(fs *flag.FlagSet) csvFlags {
+	return csvFlags{
+		delim:   fs.String("delim", ",", "csv delimiter"),
+		quote:   fs.String("quote", "\"", "csv quote character"),
+		escape:  fs.String("escape", "\"", "csv escape character"),
+		nullstr: fs.String("nullstr", "", "csv NULL string"),
+		header:  fs.Bool("header", false, "csv has a header line"),
+		schema:  fs.String("schema", "", "schema file, a JSON list of {name, type, precision, scale}"),
+	}
+}
+
+func (f csvFlags) spec() kite.CsvFileSpec {
+	return kite.NewCsvFileSpec(*f.delim, *f.quote, *f.escape, *f.nullstr, *f.header)
+}
+
+func (f csvFlags) loadSchema() ([]kite.Coldef, error) {
+	if *f.schema == "" {
+		return nil, nil
+	}
+	bv, err := os.ReadFile(*f.schema)
+	if err != nil {
+		return nil, err
+	}
+	var schema []kite.Coldef
+	err = json.Unmarshal(bv, &schema)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", *f.schema, err)
+	}
+	return schema, nil
+}
+
+func csv2xrg(args []string) int {
+	fs := flag.NewFlagSet("csv2xrg", flag.ExitOnError)
+	cf := addCsvFlags(fs)
+	rows := fs.Int("rows", kite.CSV_ROWGROUP_SIZE, "rows per row group")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: xrg csv2xrg -schema FILE [flags] IN.csv OUT.xrg")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 || *cf.schema == "" {
+		fs.Usage()
+		return 2
+	}
+
+	schema, err := cf.loadSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer in.Close()
+
+	out, err := os.Create(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = kite.CsvToXrg(in, out, cf.spec(), schema, *rows)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), err)
+		os.Remove(fs.Arg(1))
+		return 1
+	}
+	return 0
+}
+
+func xrg2csv(args []string) int {
+	fs := flag.NewFlagSet("xrg2csv", flag.ExitOnError)
+	cf := addCsvFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: xrg xrg2csv [flags] IN.xrg [OUT.csv]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return 2
+	}
+
+	schema, err := cf.loadSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	b, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var out io.WriteCloser = os.Stdout
+	if fs.NArg() == 2 {
+		out, err = os.Create(fs.Arg(1))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	err = kite.XrgToCsv(b, out, cf.spec(), schema)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	return 0
+}
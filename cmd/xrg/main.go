@@ -0,0 +1,43 @@
+// xrg is a tool for working with XRG files.
+//
+//	xrg verify FILE...
+//	xrg csv2xrg -schema FILE IN.csv OUT.xrg
+//	xrg xrg2csv IN.xrg [OUT.csv]
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"verify", "verify FILE...\n\tcheck the footer and every vector of XRG files", verify},
+	{"csv2xrg", "csv2xrg -schema FILE [flags] IN.csv OUT.xrg\n\tconvert CSV to XRG", csv2xrg},
+	{"xrg2csv", "xrg2csv [flags] IN.xrg [OUT.csv]\n\tconvert XRG to CSV", xrg2csv},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: xrg COMMAND [ARGS]\n\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  xrg %s\n", cmd.usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+	usage()
+}
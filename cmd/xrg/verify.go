@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+func verify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	quiet := fs.Bool("q", false, "only set the exit status")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: xrg verify [-q] FILE...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		problems := xrg.Verify(b)
+		if len(problems) > 0 {
+			status = 1
+		}
+		if *quiet {
+			continue
+		}
+		for _, p := range problems {
+			fmt.Printf("%s: %v\n", path, p)
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s: ok\n", path)
+		} else {
+			fmt.Printf("%s: %d problems\n", path, len(problems))
+		}
+	}
+	return status
+}
@@ -0,0 +1,168 @@
+// xrgdump prints the vector headers, compression ratio and flag counts of
+// an XRG file and optionally the first values of every vector.
+//
+//	xrgdump -n 5 test/data/gpdb0_0.xrg
+//
+// With -raw the file holds back to back vectors as captured from the wire
+// (the payload of VEC_ messages) instead of an XRG file with a footer.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+type dumper struct {
+	w      *bufio.Writer
+	nvalue int
+}
+
+func (d *dumper) vector(idx int, offset int64, group int, b []byte) error {
+	v, err := xrg.NewVector(b)
+	if err != nil {
+		return fmt.Errorf("vector %d at offset %d: %w", idx, offset, err)
+	}
+	hdr := &v.Header
+
+	ratio := 1.0
+	if hdr.Zbyte > 0 {
+		ratio = float64(hdr.Nbyte) / float64(hdr.Zbyte)
+	}
+
+	var nnull, ninval, nexcept int
+	for _, f := range v.Flag {
+		if f&xrg.XRG_FLAG_NULL != 0 {
+			nnull++
+		}
+		if f&xrg.XRG_FLAG_INVAL != 0 {
+			ninval++
+		}
+		if f&xrg.XRG_FLAG_EXCEPT != 0 {
+			nexcept++
+		}
+	}
+
+	w := d.w
+	fmt.Fprintf(w, "vector %d offset %d", idx, offset)
+	if group >= 0 {
+		fmt.Fprintf(w, " group %d", group)
+	}
+	fmt.Fprintf(w, "\n  magic %q ptyp %v ltyp %v fieldidx %d itemsz %d precision %d scale %d\n",
+		hdr.Magic[:], hdr.Ptyp, hdr.Ltyp, hdr.Fieldidx, hdr.Itemsz, hdr.Precision, hdr.Scale)
+	fmt.Fprintf(w, "  nbyte %d zbyte %d ratio %.2f nnull %d nitem %d\n", hdr.Nbyte, hdr.Zbyte, ratio, hdr.Nnull, hdr.Nitem)
+	fmt.Fprintf(w, "  flags null %d inval %d except %d\n", nnull, ninval, nexcept)
+
+	if d.nvalue > 0 {
+		return d.values(v)
+	}
+	return nil
+}
+
+func (d *dumper) values(v xrg.Vector) error {
+	// iterate over a copy without flags so that INVAL rows are not skipped
+	flag := v.Flag
+	v.Flag = make([]byte, len(flag))
+	it, err := xrg.NewIteratorSafe([]xrg.Vector{v})
+	if err != nil {
+		return err
+	}
+
+	hdr := &v.Header
+	for row := 0; row < d.nvalue && it.Next(); row++ {
+		s := xrg.FormatValue(it.Value[0], hdr.Ltyp, hdr.Precision, hdr.Scale)
+		if flag[row]&xrg.XRG_FLAG_NULL != 0 {
+			s = "NULL"
+		}
+		fmt.Fprintf(d.w, "    %d: %s", row, s)
+		if flag[row] != 0 {
+			fmt.Fprintf(d.w, " (flag %#x)", flag[row])
+		}
+		fmt.Fprintln(d.w)
+	}
+	return it.Err()
+}
+
+func (d *dumper) file(b []byte) error {
+	f, err := xrg.NewFile(b)
+	if err != nil {
+		return err
+	}
+	groups, err := f.RowGroups()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(d.w, "%d vectors in %d row groups\n", f.Nvec(), len(groups))
+	for g, group := range groups {
+		for _, i := range group {
+			raw, err := f.Raw(i)
+			if err != nil {
+				return err
+			}
+			err = d.vector(i, f.Offsets[i], g, raw)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *dumper) raw(b []byte) error {
+	offset := int64(0)
+	for i := 0; len(b) > 0; i++ {
+		v, err := xrg.NewVector(b)
+		if err != nil {
+			return fmt.Errorf("vector %d at offset %d: %w", i, offset, err)
+		}
+		sz := xrg.XRG_HEADER_SIZE + int(v.Header.Zbyte) + int(v.Header.Nitem)
+		err = d.vector(i, offset, -1, b[:sz])
+		if err != nil {
+			return err
+		}
+		b = b[sz:]
+		offset += int64(sz)
+	}
+	return nil
+}
+
+func main() {
+	nvalue := flag.Int("n", 0, "print the first N values of every vector")
+	raw := flag.Bool("raw", false, "the file holds captured vectors instead of an XRG file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-n N] [-raw] FILE...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	d := dumper{w: bufio.NewWriter(os.Stdout), nvalue: *nvalue}
+	status := 0
+	for _, path := range flag.Args() {
+		b, err := os.ReadFile(path)
+		if err == nil {
+			fmt.Fprintf(d.w, "%s: ", path)
+			if *raw {
+				fmt.Fprintln(d.w)
+				err = d.raw(b)
+			} else {
+				err = d.file(b)
+			}
+		}
+		d.w.Flush()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			status = 1
+		}
+	}
+	if status != 0 {
+		os.Exit(status)
+	}
+}
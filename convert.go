@@ -0,0 +1,210 @@
+package kite
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+// CSV_ROWGROUP_SIZE is the default number of rows per XRG row group.
+const CSV_ROWGROUP_SIZE = 1000
+
+type columnBuilder struct {
+	col     Coldef
+	builder *xrg.VectorBuilder
+	// element type of array columns
+	elemPtyp xrg.PhysicalType
+	elemLtyp xrg.LogicalType
+}
+
+func newColumnBuilder(fieldidx int, col Coldef) (*columnBuilder, error) {
+	ptyp, ltyp, err := xrg.ColumnType(col.Type, col.Precision)
+	if err != nil {
+		return nil, err
+	}
+
+	cb := &columnBuilder{col: col}
+	cb.builder = xrg.NewVectorBuilder(ptyp, ltyp, int16(fieldidx), int16(col.Precision), int16(col.Scale))
+	if ltyp == xrg.XRG_LTYP_ARRAY {
+		cb.elemPtyp, cb.elemLtyp, err = xrg.ColumnType(strings.TrimSuffix(col.Type, "[]"), col.Precision)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cb, nil
+}
+
+func (cb *columnBuilder) append(s string) error {
+	hdr := &cb.builder.Header
+	var v any
+	var err error
+	if hdr.Ltyp == xrg.XRG_LTYP_ARRAY {
+		v, err = xrg.ParseArray(s, cb.elemPtyp, cb.elemLtyp, hdr.Precision, hdr.Scale)
+	} else {
+		v, err = xrg.ParseValue(s, hdr.Ptyp, hdr.Ltyp, hdr.Precision, hdr.Scale)
+	}
+	if err != nil {
+		return fmt.Errorf("column %s: %w", cb.col.Name, err)
+	}
+	return cb.builder.Append(v)
+}
+
+// CsvToXrg reads CSV with the options of spec and writes an XRG file with
+// one vector per schema column and rowgroup rows per row group.
+func CsvToXrg(r io.Reader, w io.Writer, spec CsvFileSpec, schema []Coldef, rowgroup int) error {
+	if rowgroup <= 0 {
+		rowgroup = CSV_ROWGROUP_SIZE
+	}
+
+	cr, err := newCsvReader(r, spec)
+	if err != nil {
+		return err
+	}
+
+	builders := make([]*columnBuilder, len(schema))
+	for i, col := range schema {
+		builders[i], err = newColumnBuilder(i, col)
+		if err != nil {
+			return err
+		}
+	}
+
+	xw := xrg.NewWriter(w)
+	flush := func() error {
+		for _, cb := range builders {
+			vec, err := cb.builder.Bytes(true)
+			if err != nil {
+				return err
+			}
+			err = xw.WriteVector(vec)
+			if err != nil {
+				return err
+			}
+			cb.builder.Reset()
+		}
+		return nil
+	}
+
+	if spec.HeaderLine {
+		_, _, err = cr.Read()
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	nrow := 0
+	for {
+		fields, nulls, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(fields) != len(schema) {
+			return fmt.Errorf("line %d: %d fields, schema has %d columns", cr.line, len(fields), len(schema))
+		}
+
+		for i, cb := range builders {
+			if nulls[i] {
+				cb.builder.AppendNull()
+				continue
+			}
+			err = cb.append(fields[i])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", cr.line, err)
+			}
+		}
+
+		nrow++
+		if nrow%rowgroup == 0 {
+			err = flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if nrow%rowgroup != 0 {
+		err = flush()
+		if err != nil {
+			return err
+		}
+	}
+	return xw.Close()
+}
+
+// XrgToCsv renders the XRG file in b as CSV with the options of spec.
+// Arrays are written in postgres {..} literal syntax. schema is only used
+// for the header line and may be nil.
+func XrgToCsv(b []byte, w io.Writer, spec CsvFileSpec, schema []Coldef) error {
+	f, err := xrg.NewFile(b)
+	if err != nil {
+		return err
+	}
+	groups, err := f.RowGroups()
+	if err != nil {
+		return err
+	}
+
+	cw, err := newCsvWriter(w, spec)
+	if err != nil {
+		return err
+	}
+
+	for g, group := range groups {
+		vec := make([]xrg.Vector, len(group))
+		for i, idx := range group {
+			vec[i], err = f.Vector(idx)
+			if err != nil {
+				return err
+			}
+		}
+
+		it, err := xrg.NewIteratorSafe(vec)
+		if err != nil {
+			return fmt.Errorf("row group %d: %w", g, err)
+		}
+
+		fields := make([]string, it.Nvec)
+		nulls := make([]bool, it.Nvec)
+		arrays := make([]bool, it.Nvec)
+		for i, hdr := range it.Header {
+			arrays[i] = hdr.Ltyp == xrg.XRG_LTYP_ARRAY
+		}
+
+		if g == 0 && spec.HeaderLine {
+			for i, hdr := range it.Header {
+				fields[i] = fmt.Sprintf("col%d", i+1)
+				if int(hdr.Fieldidx) < len(schema) {
+					fields[i] = schema[hdr.Fieldidx].Name
+				}
+			}
+			err = cw.Write(fields, nulls, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		for it.Next() {
+			for i := range fields {
+				hdr := &it.Header[i]
+				nulls[i] = it.Flag[i]&xrg.XRG_FLAG_NULL != 0
+				fields[i] = ""
+				if !nulls[i] {
+					fields[i] = xrg.FormatValue(it.Value[i], hdr.Ltyp, hdr.Precision, hdr.Scale)
+				}
+			}
+			err = cw.Write(fields, nulls, arrays)
+			if err != nil {
+				return err
+			}
+		}
+		if it.Err() != nil {
+			return fmt.Errorf("row group %d: %w", g, it.Err())
+		}
+	}
+	return cw.Flush()
+}
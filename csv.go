@@ -0,0 +1,238 @@
+package kite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvOptions are the single byte delim, quote and escape characters of a
+// CsvFileSpec. quote and escape default to '"'.
+type csvOptions struct {
+	delim   byte
+	quote   byte
+	escape  byte
+	nullstr string
+}
+
+func newCsvOptions(spec CsvFileSpec) (csvOptions, error) {
+	opts := csvOptions{quote: '"', escape: '"', nullstr: spec.Nullstr}
+	if len(spec.Delim) != 1 {
+		return opts, fmt.Errorf("csv delim must be a single character")
+	}
+	opts.delim = spec.Delim[0]
+	if len(spec.Quote) > 1 || len(spec.Escape) > 1 {
+		return opts, fmt.Errorf("csv quote and escape must be a single character")
+	}
+	if len(spec.Quote) == 1 {
+		opts.quote = spec.Quote[0]
+	}
+	if len(spec.Escape) == 1 {
+		opts.escape = spec.Escape[0]
+	}
+	if opts.delim == opts.quote {
+		return opts, fmt.Errorf("csv delim and quote must differ")
+	}
+	return opts, nil
+}
+
+// csvReader reads records with postgres COPY CSV semantics: an unquoted
+// field equal to nullstr is NULL, inside quotes the escape character
+// escapes the quote and itself. Like kite, an unquoted field starting with
+// '{' is an array literal and extends to the matching '}'.
+type csvReader struct {
+	csvOptions
+	r    *bufio.Reader
+	line int
+}
+
+func newCsvReader(r io.Reader, spec CsvFileSpec) (*csvReader, error) {
+	opts, err := newCsvOptions(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &csvReader{csvOptions: opts, r: bufio.NewReader(r)}, nil
+}
+
+// Read returns the fields of the next record and which of them are NULL.
+// It returns io.EOF when there are no more records.
+func (cr *csvReader) Read() (fields []string, nulls []bool, err error) {
+	var sb strings.Builder
+	quoted := false
+	inquote := false
+	started := false
+	cr.line++
+
+	endField := func() {
+		s := sb.String()
+		fields = append(fields, s)
+		nulls = append(nulls, !quoted && s == cr.nullstr)
+		sb.Reset()
+		quoted = false
+	}
+
+	for {
+		c, err := cr.r.ReadByte()
+		if err == io.EOF {
+			if inquote {
+				return nil, nil, fmt.Errorf("line %d: unterminated quoted field", cr.line)
+			}
+			if !started {
+				return nil, nil, io.EOF
+			}
+			endField()
+			return fields, nulls, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		started = true
+
+		if inquote {
+			if c == cr.escape && cr.escape != cr.quote {
+				next, err := cr.r.ReadByte()
+				if err != nil {
+					return nil, nil, fmt.Errorf("line %d: unterminated quoted field", cr.line)
+				}
+				if next != cr.quote && next != cr.escape {
+					sb.WriteByte(c)
+				}
+				sb.WriteByte(next)
+				continue
+			}
+			if c == cr.quote {
+				next, err := cr.r.Peek(1)
+				if err == nil && cr.escape == cr.quote && next[0] == cr.quote {
+					cr.r.ReadByte()
+					sb.WriteByte(c)
+					continue
+				}
+				inquote = false
+				continue
+			}
+			if c == '\n' {
+				cr.line++
+			}
+			sb.WriteByte(c)
+			continue
+		}
+
+		if c == '{' && sb.Len() == 0 && !quoted {
+			err = cr.readArray(&sb)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		switch c {
+		case cr.quote:
+			inquote = true
+			quoted = true
+		case cr.delim:
+			endField()
+		case '\n':
+			endField()
+			return fields, nulls, nil
+		case '\r':
+			if next, err := cr.r.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// readArray reads an array literal after the opening '{' up to the
+// matching '}', skipping over double quoted elements.
+func (cr *csvReader) readArray(sb *strings.Builder) error {
+	depth := 1
+	inquote := false
+	sb.WriteByte('{')
+	for depth > 0 {
+		c, err := cr.r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("line %d: unterminated array", cr.line)
+		}
+		sb.WriteByte(c)
+
+		switch {
+		case c == '\\' && inquote:
+			c, err = cr.r.ReadByte()
+			if err != nil {
+				return fmt.Errorf("line %d: unterminated array", cr.line)
+			}
+			sb.WriteByte(c)
+		case c == '"':
+			inquote = !inquote
+		case c == '{' && !inquote:
+			depth++
+		case c == '}' && !inquote:
+			depth--
+		case c == '\n':
+			cr.line++
+		}
+	}
+	return nil
+}
+
+type csvWriter struct {
+	csvOptions
+	w *bufio.Writer
+}
+
+func newCsvWriter(w io.Writer, spec CsvFileSpec) (*csvWriter, error) {
+	opts, err := newCsvOptions(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &csvWriter{csvOptions: opts, w: bufio.NewWriter(w)}, nil
+}
+
+func (cw *csvWriter) needQuote(s string) bool {
+	if s == cw.nullstr {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == cw.delim || c == cw.quote || c == cw.escape || c == '\n' || c == '\r' {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes one record. NULL fields are written as nullstr. Array
+// literals are written without quotes, see readArray. arrays may be nil.
+func (cw *csvWriter) Write(fields []string, nulls []bool, arrays []bool) error {
+	for i, s := range fields {
+		if i > 0 {
+			cw.w.WriteByte(cw.delim)
+		}
+		if nulls[i] {
+			cw.w.WriteString(cw.nullstr)
+			continue
+		}
+		if (arrays != nil && arrays[i]) || !cw.needQuote(s) {
+			cw.w.WriteString(s)
+			continue
+		}
+
+		cw.w.WriteByte(cw.quote)
+		for j := 0; j < len(s); j++ {
+			if s[j] == cw.quote || s[j] == cw.escape {
+				cw.w.WriteByte(cw.escape)
+			}
+			cw.w.WriteByte(s[j])
+		}
+		cw.w.WriteByte(cw.quote)
+	}
+	return cw.w.WriteByte('\n')
+}
+
+func (cw *csvWriter) Flush() error {
+	return cw.w.Flush()
+}
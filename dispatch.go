@@ -0,0 +1,325 @@
+package kite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/vderic/kite-client-go/client"
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+// MaxConcurrentFragments limits the number of fragments running at once.
+// The other fragments are queued and dispatched as running ones finish.
+// The default 0 runs every fragment at once.
+func (c *KiteClient) MaxConcurrentFragments(n int) *KiteClient {
+	c.maxfrags = n
+	return c
+}
+
+// MaxFragmentsPerHost limits the number of fragments running at once on
+// each host. The default 0 means no limit.
+func (c *KiteClient) MaxFragmentsPerHost(n int) *KiteClient {
+	c.maxperhost = n
+	return c
+}
+
+// Placement selects how fragments are assigned to hosts. The default is
+// RoundRobin.
+func (c *KiteClient) Placement(p Placement) *KiteClient {
+	c.placement = p
+	return c
+}
+
+// HealthTracker routes fragments only to the hosts t considers healthy and
+// reports connection failures and server errors to it. A host that cannot
+// be reached is skipped and the fragment is placed on another host.
+func (c *KiteClient) HealthTracker(t *HealthTracker) *KiteClient {
+	c.health = t
+	return c
+}
+
+// Dialer replaces the function connecting to the hosts, e.g. to go through
+// a proxy or bind a source address. network is "unix" for hosts given as
+// unix:///path/to/socket and "tcp" otherwise. ctx is canceled by Cancel and
+// Close. With a ConnPool, the connections it dials are only reused by this
+// client.
+func (c *KiteClient) Dialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) *KiteClient {
+	c.dialer = dial
+	c.dialkey = ""
+	if dial != nil {
+		c.dialkey = fmt.Sprintf("dialer#%d", atomic.AddInt64(&ndialer, 1))
+	}
+	return c
+}
+
+// ndialer numbers the Dialer calls
+var ndialer int64
+
+// ConnPool makes the client take connections from p and return them to p
+// once their fragment is done. The kite servers must accept more than one
+// request per connection.
+func (c *KiteClient) ConnPool(p *ConnPool) *KiteClient {
+	c.connpool = p
+	return c
+}
+
+// dispatch connects queued fragments while the concurrency limits allow.
+func (c *KiteClient) dispatch() error {
+	// hosts that failed during this dispatch
+	failed := make(map[int]bool)
+	var lastErr error
+
+	for len(c.queue) > 0 {
+		if c.canceled.Load() {
+			return ErrCanceled
+		}
+		if c.maxfrags > 0 && len(c.sss) >= c.maxfrags {
+			return nil
+		}
+		fragid := c.queue[0].Fragment[0]
+		h := c.placeFragment(fragid, failed)
+		if h < 0 {
+			if len(c.sss) == 0 {
+				if lastErr != nil {
+					return lastErr
+				}
+				return fmt.Errorf("no host available for fragment %d", fragid)
+			}
+			return nil
+		}
+
+		frag := &fragment{req: c.queue[0], start: time.Now()}
+		err := c.connect(h, frag)
+		if err != nil {
+			if err == ErrCanceled || c.health == nil {
+				return err
+			}
+			c.health.ReportFailure(c.hosts[h], err)
+			failed[h] = true
+			lastErr = err
+			continue
+		}
+		c.queue = c.queue[1:]
+	}
+	return nil
+}
+
+// placeFragment returns the host for the fragment, -1 if all hosts that
+// could take it are busy.
+func (c *KiteClient) placeFragment(fragid int, failed map[int]bool) int {
+	loads := make([]HostLoad, len(c.hosts))
+	for h, addr := range c.hosts {
+		available := c.maxperhost <= 0 || c.running[h] < c.maxperhost
+		if failed[h] || (c.health != nil && !c.health.Healthy(addr)) {
+			available = false
+		}
+		loads[h] = HostLoad{
+			Addr:      addr,
+			Running:   c.running[h],
+			Available: available,
+		}
+	}
+
+	placement := c.placement
+	if placement == nil {
+		placement = RoundRobin()
+	}
+	h := placement.Place(fragid, loads)
+	if h >= len(c.hosts) {
+		return -1
+	}
+	return h
+}
+
+// fragment is a fragment of the query and its attempts, more than one
+// when it is hedged.
+type fragment struct {
+	req      Request
+	start    time.Time
+	attempts []*fragConn
+	done     bool
+	// delivered is set once a page went to NextRow, the fragment can no
+	// longer be hedged
+	delivered bool
+}
+
+// connect sends the request of a fragment to host h.
+func (c *KiteClient) connect(h int, frag *fragment) error {
+	js, err := json.Marshal(frag.req)
+	if err != nil {
+		return err
+	}
+
+	conn, err := c.dial(c.hosts[h])
+	if err != nil {
+		if c.canceled.Load() {
+			return ErrCanceled
+		}
+		return err
+	}
+
+	// add to the poller, which may wrap the connection
+	pconn, err := c.poller.Add(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	ss := &client.SockStream{Conn: pconn}
+
+	// send message
+	err = ss.Send(client.KITE_MESSAGE_KIT1, nil)
+	if err == nil {
+		err = ss.Send(client.KITE_MESSAGE_JSON, js)
+	}
+	if err != nil {
+		c.poller.Remove(pconn)
+		ss.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.canceled.Load() {
+		c.poller.Remove(pconn)
+		ss.Cancel()
+		return ErrCanceled
+	}
+	fc := &fragConn{ss: ss, conn: pconn, raw: conn, host: h, frag: frag}
+	frag.attempts = append(frag.attempts, fc)
+	c.sss[pconn] = fc
+	c.running[h]++
+	return nil
+}
+
+// deliver queues pages for NextRow.
+func (c *KiteClient) deliver(pages []*xrg.Page) error {
+	for i, p := range pages {
+		iter, err := c.newIterator(p)
+		if err != nil {
+			for _, p := range pages[i+1:] {
+				p.Release()
+			}
+			return err
+		}
+		c.pages = append(c.pages, iter)
+	}
+	return nil
+}
+
+// finish closes an attempt that received BYE_. The first attempt of a
+// fragment to finish wins: its held pages are delivered and the other
+// attempts are dropped.
+func (c *KiteClient) finish(fc *fragConn) error {
+	if c.health != nil {
+		c.health.ReportSuccess(c.hosts[fc.host])
+	}
+	c.release(fc)
+
+	frag := fc.frag
+	if !frag.done {
+		frag.done = true
+		c.durations = append(c.durations, time.Since(frag.start))
+		held := fc.held
+		fc.held = nil
+		err := c.deliver(held)
+		if err != nil {
+			return err
+		}
+	}
+	fc.free()
+
+	for _, a := range frag.attempts {
+		if a != fc {
+			c.drop(a)
+		}
+	}
+	return c.dispatch()
+}
+
+// close unregisters and closes the connection of an attempt.
+func (c *KiteClient) close(fc *fragConn) {
+	c.closeConn(fc, false)
+}
+
+// release unregisters the connection of a finished attempt and returns it
+// to the connection pool when it can serve another request.
+func (c *KiteClient) release(fc *fragConn) {
+	reuse := c.connpool != nil && !fc.dirty && !fc.ss.Pending() && fc.conn == fc.raw
+	c.closeConn(fc, reuse)
+}
+
+func (c *KiteClient) closeConn(fc *fragConn, reuse bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.sss[fc.conn]; !ok {
+		return
+	}
+	c.poller.Remove(fc.conn)
+	if reuse && !c.canceled.Load() {
+		c.connpool.put(c.poolKey(c.hosts[fc.host]), fc.raw)
+	} else {
+		fc.ss.Close()
+	}
+	delete(c.sss, fc.conn)
+	c.running[fc.host]--
+}
+
+// splitAddr returns the network and address of a host, "unix" for
+// unix:///path/to/socket and "tcp" otherwise.
+func splitAddr(addr string) (string, string) {
+	if strings.HasPrefix(addr, "unix://") {
+		return "unix", strings.TrimPrefix(addr, "unix://")
+	}
+	return "tcp", addr
+}
+
+// dial connects to addr, reusing an idle connection of the pool if any.
+func (c *KiteClient) dial(addr string) (net.Conn, error) {
+	c.mu.Lock()
+	ctx := c.ctx
+	c.mu.Unlock()
+
+	dial := func() (net.Conn, error) {
+		network, address := splitAddr(addr)
+		if c.dialer != nil {
+			return c.dialer(ctx, network, address)
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+	if c.connpool != nil {
+		return c.connpool.get(c.poolKey(addr), dial)
+	}
+	return dial()
+}
+
+// poolKey returns the key of the pooled connections to addr, which must
+// not be shared between dialers.
+func (c *KiteClient) poolKey(addr string) string {
+	if c.dialkey == "" {
+		return addr
+	}
+	return addr + " " + c.dialkey
+}
+
+// drop closes an attempt and discards what it received.
+func (c *KiteClient) drop(fc *fragConn) {
+	c.close(fc)
+	fc.discard()
+}
+
+// otherAttempts reports whether another attempt of the fragment of fc is
+// still running.
+func (c *KiteClient) otherAttempts(fc *fragConn) bool {
+	for _, a := range fc.frag.attempts {
+		if _, ok := c.sss[a.conn]; ok && a != fc {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package kite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vderic/kite-client-go/kitetest"
+)
+
+func slowPages(s *kitetest.Server) {
+	s.PageDelay = 100 * time.Millisecond
+}
+
+func TestMaxConcurrentFragments(t *testing.T) {
+	srv, schema := startServer(t, slowPages)
+	want := scanAll(t, newTestClient(srv, schema, 1)) * 8
+	if srv.MaxActive() != 1 {
+		t.Fatalf("one fragment: got %d active requests", srv.MaxActive())
+	}
+
+	srv, schema = startServer(t, slowPages)
+	c := newTestClient(srv, schema, 8).MaxConcurrentFragments(3)
+	if n := scanAll(t, c); n != want {
+		t.Errorf("got %d rows, want %d", n, want)
+	}
+	if n := len(srv.Requests()); n != 8 {
+		t.Errorf("got %d requests, want 8", n)
+	}
+	if n := srv.MaxActive(); n > 3 || n < 2 {
+		t.Errorf("got %d active requests, want at most 3 and more than 1", n)
+	}
+}
+
+func TestMaxFragmentsPerHost(t *testing.T) {
+	srv1, schema := startServer(t, slowPages)
+	want := scanAll(t, newTestClient(srv1, schema, 1)) * 8
+
+	srv1, _ = startServer(t, slowPages)
+	srv2, _ := startServer(t, slowPages)
+	c := newTestClient(srv1, schema, 8).Host([]string{srv1.Addr, srv2.Addr}).MaxFragmentsPerHost(2)
+	if n := scanAll(t, c); n != want {
+		t.Errorf("got %d rows, want %d", n, want)
+	}
+	if n := len(srv1.Requests()) + len(srv2.Requests()); n != 8 {
+		t.Errorf("got %d requests, want 8", n)
+	}
+	for _, srv := range []*kitetest.Server{srv1, srv2} {
+		if n := srv.MaxActive(); n > 2 {
+			t.Errorf("%s: got %d active requests, want at most 2", srv.Addr, n)
+		}
+	}
+}
@@ -3,11 +3,12 @@ module github.com/vderic/kite-client-go
 go 1.19
 
 require (
+	github.com/peterh/liner v1.2.2
 	github.com/pierrec/lz4 v2.6.1+incompatible
-	github.com/smallnest/epoller v1.2.0
+	golang.org/x/sys v0.0.0-20211117180635-dee7805ff2e1
 )
 
 require (
+	github.com/mattn/go-runewidth v0.0.3 // indirect
 	github.com/shabbyrobe/go-num v0.0.0-20220218224608-bad1c8f534d7 // indirect
-	golang.org/x/sys v0.0.0-20210525143221-35b2ab0089ea // indirect
 )
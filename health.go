@@ -0,0 +1,186 @@
+package kite
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// HealthTracker records dial failures and server errors per host and
+// ejects a host after threshold consecutive failures. An ejected host is
+// probed in the background after a cooldown that doubles with every failed
+// probe, and gets fragments again once a probe succeeds. A tracker can be
+// shared by any number of KiteClients.
+type HealthTracker struct {
+	mu        sync.Mutex
+	hosts     map[string]*hostHealth
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	probe     func(addr string) error
+	done      chan struct{}
+	closed    bool
+}
+
+type hostHealth struct {
+	failures int
+	ejected  bool
+	cooldown time.Duration
+	until    time.Time
+	lastErr  error
+}
+
+// HostStatus is a snapshot of the health of a host.
+type HostStatus struct {
+	Addr     string
+	Healthy  bool
+	Failures int
+	Until    time.Time // end of the current cooldown of an ejected host
+	LastErr  error
+}
+
+func NewHealthTracker() *HealthTracker {
+	t := new(HealthTracker)
+	t.hosts = make(map[string]*hostHealth)
+	t.threshold = 1
+	t.base = time.Second
+	t.max = time.Minute
+	t.probe = dialProbe
+	t.done = make(chan struct{})
+	return t
+}
+
+func dialProbe(addr string) error {
+	network, address := splitAddr(addr)
+	conn, err := net.DialTimeout(network, address, time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Threshold sets the number of consecutive failures that eject a host.
+func (t *HealthTracker) Threshold(n int) *HealthTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n < 1 {
+		n = 1
+	}
+	t.threshold = n
+	return t
+}
+
+// Cooldown sets the first and the maximum time a host stays ejected.
+func (t *HealthTracker) Cooldown(base, max time.Duration) *HealthTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.base = base
+	t.max = max
+	return t
+}
+
+// Probe replaces the check run on ejected hosts, a direct dial by default.
+// Set it when the clients connect through a custom Dialer.
+func (t *HealthTracker) Probe(probe func(addr string) error) *HealthTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probe = probe
+	return t
+}
+
+// Healthy reports whether fragments may be sent to addr.
+func (t *HealthTracker) Healthy(addr string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.hosts[addr]
+	return !ok || !h.ejected
+}
+
+func (t *HealthTracker) ReportSuccess(addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h, ok := t.hosts[addr]; ok && !h.ejected {
+		h.failures = 0
+	}
+}
+
+func (t *HealthTracker) ReportFailure(addr string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.hosts[addr]
+	if !ok {
+		h = new(hostHealth)
+		t.hosts[addr] = h
+	}
+	h.failures++
+	h.lastErr = err
+	if h.ejected || h.failures < t.threshold || t.closed {
+		return
+	}
+
+	h.ejected = true
+	h.cooldown = t.base
+	h.until = time.Now().Add(h.cooldown)
+	go t.probeLoop(addr, h)
+}
+
+// probeLoop probes an ejected host after every cooldown until it answers.
+func (t *HealthTracker) probeLoop(addr string, h *hostHealth) {
+	for {
+		t.mu.Lock()
+		wait := time.Until(h.until)
+		probe := t.probe
+		t.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-t.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := probe(addr)
+
+		t.mu.Lock()
+		if err == nil {
+			h.ejected = false
+			h.failures = 0
+			h.lastErr = nil
+			t.mu.Unlock()
+			return
+		}
+		h.lastErr = err
+		h.cooldown *= 2
+		if h.cooldown > t.max {
+			h.cooldown = t.max
+		}
+		h.until = time.Now().Add(h.cooldown)
+		t.mu.Unlock()
+	}
+}
+
+// Status returns the health of every host that has failed so far.
+func (t *HealthTracker) Status() []HostStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := make([]HostStatus, 0, len(t.hosts))
+	for addr, h := range t.hosts {
+		s := HostStatus{Addr: addr, Healthy: !h.ejected, Failures: h.failures, LastErr: h.lastErr}
+		if h.ejected {
+			s.Until = h.until
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+// Close stops the background probes. Ejected hosts stay ejected.
+func (t *HealthTracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+}
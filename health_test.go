@@ -0,0 +1,158 @@
+package kite
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// deadAddr returns an address nothing listens on.
+func deadAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func refuse(addr string) error {
+	return errors.New("refused")
+}
+
+func TestHealthEject(t *testing.T) {
+	ht := NewHealthTracker().Threshold(2).Cooldown(time.Hour, time.Hour).Probe(refuse)
+	defer ht.Close()
+
+	errDown := errors.New("down")
+	ht.ReportFailure("a", errDown)
+	if !ht.Healthy("a") {
+		t.Fatal("ejected after one failure with threshold 2")
+	}
+	ht.ReportSuccess("a")
+	ht.ReportFailure("a", errDown)
+	if !ht.Healthy("a") {
+		t.Fatal("success did not reset the failure count")
+	}
+	ht.ReportFailure("a", errDown)
+	if ht.Healthy("a") {
+		t.Fatal("not ejected after two consecutive failures")
+	}
+	if !ht.Healthy("b") {
+		t.Error("unknown host is not healthy")
+	}
+
+	// a success does not bring an ejected host back, only a probe does
+	ht.ReportSuccess("a")
+	if ht.Healthy("a") {
+		t.Error("ejected host healthy after ReportSuccess")
+	}
+
+	status := ht.Status()
+	if len(status) != 1 {
+		t.Fatalf("got status %v", status)
+	}
+	s := status[0]
+	if s.Addr != "a" || s.Healthy || s.Failures != 2 || s.LastErr != errDown || time.Until(s.Until) < 59*time.Minute {
+		t.Errorf("got status %+v", s)
+	}
+}
+
+func TestHealthRecover(t *testing.T) {
+	var up atomic.Bool
+	var probes atomic.Int32
+	ht := NewHealthTracker().Cooldown(5*time.Millisecond, 10*time.Millisecond).Probe(func(addr string) error {
+		probes.Add(1)
+		if !up.Load() {
+			return errors.New("still down")
+		}
+		return nil
+	})
+	defer ht.Close()
+
+	ht.ReportFailure("a", errors.New("down"))
+	if ht.Healthy("a") {
+		t.Fatal("not ejected")
+	}
+	for probes.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if ht.Healthy("a") {
+		t.Fatal("healthy after a failed probe")
+	}
+
+	up.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for !ht.Healthy("a") {
+		if time.Now().After(deadline) {
+			t.Fatal("not healthy after a successful probe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s := ht.Status()[0]
+	if !s.Healthy || s.Failures != 0 || s.LastErr != nil {
+		t.Errorf("got status %+v", s)
+	}
+}
+
+func TestHealthDispatch(t *testing.T) {
+	srv, schema := startServer(t, nil)
+	want := scanAll(t, newTestClient(srv, schema, 4))
+
+	dead := deadAddr(t)
+	ht := NewHealthTracker().Cooldown(time.Hour, time.Hour).Probe(refuse)
+	defer ht.Close()
+
+	// the query succeeds on the live host and ejects the dead one
+	c := newTestClient(srv, schema, 4).Host([]string{dead, srv.Addr}).HealthTracker(ht)
+	if n := scanAll(t, c); n != want {
+		t.Errorf("got %d rows, want %d", n, want)
+	}
+	if ht.Healthy(dead) || !ht.Healthy(srv.Addr) {
+		t.Errorf("got status %+v", ht.Status())
+	}
+
+	// the next query does not try the dead host at all
+	dials := 0
+	c = newTestClient(srv, schema, 4).Host([]string{dead, srv.Addr}).HealthTracker(ht)
+	c.Dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == dead {
+			dials++
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	})
+	if n := scanAll(t, c); n != want {
+		t.Errorf("got %d rows, want %d", n, want)
+	}
+	if dials != 0 {
+		t.Errorf("dialed the ejected host %d times", dials)
+	}
+}
+
+func TestHealthAllEjected(t *testing.T) {
+	srv, schema := startServer(t, nil)
+	dead1, dead2 := deadAddr(t), deadAddr(t)
+	ht := NewHealthTracker().Cooldown(time.Hour, time.Hour).Probe(refuse)
+	defer ht.Close()
+
+	c := newTestClient(srv, schema, 2).Host([]string{dead1, dead2}).HealthTracker(ht)
+	defer c.Close()
+	if err := c.Submit(); err == nil {
+		t.Fatal("Submit with no live host succeeded")
+	}
+	if ht.Healthy(dead1) || ht.Healthy(dead2) {
+		t.Fatalf("got status %+v", ht.Status())
+	}
+
+	c = newTestClient(srv, schema, 2).Host([]string{dead1, dead2}).HealthTracker(ht)
+	defer c.Close()
+	if err := c.Submit(); err == nil {
+		t.Fatal("Submit with all hosts ejected succeeded")
+	}
+}
@@ -0,0 +1,76 @@
+package kite
+
+import (
+	"sort"
+	"time"
+)
+
+// Hedge enables hedged requests. Once minDone fragments have finished, a
+// fragment running longer than factor times the percentile (0-100) of
+// their durations is sent again to another host. The first copy to finish
+// is used and the other one is closed. Only fragments that have not returned
+// a page yet are hedged, and once hedged their pages are held back until a
+// copy finishes, so NextRow only returns the rows of one copy.
+func (c *KiteClient) Hedge(percentile float64, factor float64, minDone int) *KiteClient {
+	c.hedging = true
+	c.hedgepct = percentile
+	c.hedgefac = factor
+	c.hedgemin = minDone
+	if c.hedgemin < 1 {
+		c.hedgemin = 1
+	}
+	return c
+}
+
+// hedgeThreshold returns the running time after which a fragment is
+// hedged, false while too few fragments have finished.
+func (c *KiteClient) hedgeThreshold() (time.Duration, bool) {
+	if len(c.durations) < c.hedgemin {
+		return 0, false
+	}
+
+	d := append([]time.Duration(nil), c.durations...)
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	i := int(c.hedgepct / 100 * float64(len(d)-1))
+	if i < 0 {
+		i = 0
+	} else if i >= len(d) {
+		i = len(d) - 1
+	}
+	return time.Duration(float64(d[i]) * c.hedgefac), true
+}
+
+// hedge sends a copy of every straggling fragment to another host.
+func (c *KiteClient) hedge() error {
+	threshold, ok := c.hedgeThreshold()
+	if !ok {
+		return nil
+	}
+
+	var stragglers []*fragment
+	for _, fc := range c.sss {
+		frag := fc.frag
+		if len(frag.attempts) == 1 && !frag.delivered && time.Since(frag.start) > threshold {
+			stragglers = append(stragglers, frag)
+		}
+	}
+	// hedge in fragment order to keep placement reproducible
+	sort.Slice(stragglers, func(i, j int) bool {
+		return stragglers[i].req.Fragment[0] < stragglers[j].req.Fragment[0]
+	})
+
+	for _, frag := range stragglers {
+		if c.maxfrags > 0 && len(c.sss) >= c.maxfrags {
+			return nil
+		}
+		h := c.placeFragment(frag.req.Fragment[0], map[int]bool{frag.attempts[0].host: true})
+		if h < 0 {
+			continue
+		}
+		err := c.connect(h, frag)
+		if err != nil && err != ErrCanceled && c.health != nil {
+			c.health.ReportFailure(c.hosts[h], err)
+		}
+	}
+	return nil
+}
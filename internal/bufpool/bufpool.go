@@ -0,0 +1,49 @@
+// Package bufpool keeps size-classed pools of byte buffers for message
+// receive and vector decompression.
+package bufpool
+
+import (
+	"math/bits"
+	"sync"
+)
+
+const (
+	minShift = 9  // 512 bytes
+	maxShift = 26 // 64 MB
+)
+
+var pools [maxShift - minShift + 1]sync.Pool
+
+func class(n int) int {
+	if n <= 1<<minShift {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - minShift
+}
+
+// Get returns a buffer of length n. Buffers larger than the biggest class
+// are allocated and never pooled.
+func Get(n int) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	c := class(n)
+	if c >= len(pools) {
+		return make([]byte, n)
+	}
+	if p, ok := pools[c].Get().(*[]byte); ok {
+		return (*p)[:n]
+	}
+	return make([]byte, n, 1<<(c+minShift))
+}
+
+// Put returns a buffer obtained from Get. The buffer must not be used
+// afterwards. Buffers not allocated by Get are dropped.
+func Put(b []byte) {
+	c := class(cap(b))
+	if cap(b) == 0 || c >= len(pools) || cap(b) != 1<<(c+minShift) {
+		return
+	}
+	b = b[:cap(b)]
+	pools[c].Put(&b)
+}
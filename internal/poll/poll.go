@@ -0,0 +1,245 @@
+// Package poll waits for many connections to become readable at once.
+package poll
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Poller reports which of its connections are readable. Readiness is level
+// triggered: a connection is reported by every Wait until its pending data
+// has been read.
+type Poller interface {
+	// Add registers conn and returns the connection to read from, which
+	// may wrap conn.
+	Add(conn net.Conn) (net.Conn, error)
+	// Remove unregisters a connection returned by Add. It must be called
+	// before the connection is closed.
+	Remove(conn net.Conn) error
+	// Wait returns up to the poller size readable connections, waiting at
+	// most timeout for one. A negative timeout waits forever.
+	Wait(timeout time.Duration) ([]net.Conn, error)
+	Close() error
+}
+
+const DefaultSize = 256
+
+// NewGoroutinePoller returns a poller that reads every connection from its
+// own goroutine. It works with any net.Conn.
+func NewGoroutinePoller(size int) Poller {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &goPoller{
+		size:   size,
+		signal: make(chan struct{}, 1),
+		conns:  make(map[*pipeConn]bool),
+	}
+}
+
+type goPoller struct {
+	size   int
+	signal chan struct{}
+	// wake is called when a connection becomes readable, if set
+	wake func()
+
+	mu    sync.Mutex
+	conns map[*pipeConn]bool
+	queue []*pipeConn
+	last  []*pipeConn
+}
+
+func (p *goPoller) Add(conn net.Conn) (net.Conn, error) {
+	c := &pipeConn{Conn: conn, poller: p, buf: make([]byte, 64*1024)}
+	c.cond = sync.NewCond(&c.mu)
+
+	p.mu.Lock()
+	p.conns[c] = true
+	p.mu.Unlock()
+
+	go c.reader()
+	return c, nil
+}
+
+func (p *goPoller) Remove(conn net.Conn) error {
+	c, ok := conn.(*pipeConn)
+	if !ok {
+		return net.ErrClosed
+	}
+
+	p.mu.Lock()
+	delete(p.conns, c)
+	p.mu.Unlock()
+	c.stop()
+	return nil
+}
+
+func (p *goPoller) notify(c *pipeConn) {
+	p.mu.Lock()
+	if p.conns[c] && !c.queued {
+		c.queued = true
+		p.queue = append(p.queue, c)
+	}
+	// under the lock so that wake is not called after Close
+	if p.wake != nil && p.conns[c] {
+		p.wake()
+	}
+	p.mu.Unlock()
+
+	select {
+	case p.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (p *goPoller) Wait(timeout time.Duration) ([]net.Conn, error) {
+	var timer <-chan time.Time
+	if timeout >= 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	for {
+		ready := p.ready(p.size)
+		if len(ready) > 0 || timeout == 0 {
+			return ready, nil
+		}
+
+		select {
+		case <-p.signal:
+		case <-timer:
+			return nil, nil
+		}
+	}
+}
+
+// pending reports whether ready would return any connection.
+func (p *goPoller) pending() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) > 0 {
+		return true
+	}
+	for _, c := range p.last {
+		if p.conns[c] && c.readable() {
+			return true
+		}
+	}
+	return false
+}
+
+// ready collects up to max of the queued connections and those returned by
+// the last Wait that still have data.
+func (p *goPoller) ready(max int) []net.Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var ready []net.Conn
+	last := p.last
+	p.last = nil
+	for _, c := range last {
+		if len(ready) < max && p.conns[c] && !c.queued && c.readable() {
+			ready = append(ready, c)
+			p.last = append(p.last, c)
+		}
+	}
+	n := 0
+	for n < len(p.queue) && len(ready) < max {
+		c := p.queue[n]
+		c.queued = false
+		if p.conns[c] {
+			ready = append(ready, c)
+			p.last = append(p.last, c)
+		}
+		n++
+	}
+	p.queue = p.queue[n:]
+	return ready
+}
+
+func (p *goPoller) Close() error {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[*pipeConn]bool)
+	p.queue = nil
+	p.last = nil
+	p.mu.Unlock()
+
+	for c := range conns {
+		c.stop()
+	}
+	return nil
+}
+
+// pipeConn hands the data read by its goroutine to the caller of Read.
+// The goroutine does not read again until the previous chunk is consumed.
+type pipeConn struct {
+	net.Conn
+	poller *goPoller
+	queued bool // protected by poller.mu
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []byte
+	pending []byte
+	err     error
+	stopped bool
+}
+
+func (c *pipeConn) reader() {
+	for {
+		n, err := c.Conn.Read(c.buf)
+
+		c.mu.Lock()
+		c.pending = c.buf[:n]
+		c.err = err
+		c.cond.Broadcast()
+		c.mu.Unlock()
+		c.poller.notify(c)
+
+		c.mu.Lock()
+		for len(c.pending) > 0 && !c.stopped {
+			c.cond.Wait()
+		}
+		stopped := c.stopped
+		c.mu.Unlock()
+		if err != nil || stopped {
+			return
+		}
+	}
+}
+
+func (c *pipeConn) readable() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending) > 0 || c.err != nil
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.pending) == 0 && c.err == nil && !c.stopped {
+		c.cond.Wait()
+	}
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		if len(c.pending) == 0 {
+			c.cond.Broadcast()
+		}
+		return n, nil
+	}
+	if c.stopped {
+		return 0, net.ErrClosed
+	}
+	return 0, c.err
+}
+
+func (c *pipeConn) stop() {
+	c.mu.Lock()
+	c.stopped = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
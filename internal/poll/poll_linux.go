@@ -0,0 +1,194 @@
+//go:build linux
+
+package poll
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// New returns an epoll based poller returning up to size connections per
+// Wait. Connections without a file descriptor are read from their own
+// goroutine as with NewGoroutinePoller.
+func New(size int) (Poller, error) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	fd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return &epoll{
+		fd:     fd,
+		wakefd: -1,
+		events: make([]unix.EpollEvent, size),
+		conns:  make(map[int32]net.Conn),
+	}, nil
+}
+
+type epoll struct {
+	fd     int
+	events []unix.EpollEvent
+
+	mu    sync.Mutex
+	conns map[int32]net.Conn
+	// fallback reads the connections without a file descriptor and
+	// signals wakefd, an eventfd in the epoll set, when one is readable.
+	fallback *goPoller
+	wakefd   int
+}
+
+var errNoFd = errors.New("poll: connection has no file descriptor")
+
+func connFd(conn net.Conn) (int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return -1, errNoFd
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	fd := -1
+	err = raw.Control(func(f uintptr) {
+		fd = int(f)
+	})
+	return fd, err
+}
+
+func (e *epoll) Add(conn net.Conn) (net.Conn, error) {
+	fd, err := connFd(conn)
+	if err == errNoFd {
+		fallback, err := e.getFallback()
+		if err != nil {
+			return nil, err
+		}
+		return fallback.Add(conn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// hangups and errors are reported as readable so that Read returns them
+	ev := unix.EpollEvent{Events: unix.EPOLLIN | unix.EPOLLRDHUP, Fd: int32(fd)}
+	err = unix.EpollCtl(e.fd, unix.EPOLL_CTL_ADD, fd, &ev)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.conns[int32(fd)] = conn
+	e.mu.Unlock()
+	return conn, nil
+}
+
+// getFallback returns the goroutine poller, creating it on first use.
+func (e *epoll) getFallback() (*goPoller, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.fallback != nil {
+		return e.fallback, nil
+	}
+
+	wakefd, err := unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	ev := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(wakefd)}
+	err = unix.EpollCtl(e.fd, unix.EPOLL_CTL_ADD, wakefd, &ev)
+	if err != nil {
+		unix.Close(wakefd)
+		return nil, err
+	}
+
+	e.wakefd = wakefd
+	e.fallback = NewGoroutinePoller(len(e.events)).(*goPoller)
+	e.fallback.wake = func() {
+		one := [8]byte{1}
+		unix.Write(wakefd, one[:])
+	}
+	return e.fallback, nil
+}
+
+func (e *epoll) getFallbackIfAny() *goPoller {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.fallback
+}
+
+func (e *epoll) Remove(conn net.Conn) error {
+	if c, ok := conn.(*pipeConn); ok {
+		return c.poller.Remove(c)
+	}
+	fd, err := connFd(conn)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	delete(e.conns, int32(fd))
+	e.mu.Unlock()
+	return unix.EpollCtl(e.fd, unix.EPOLL_CTL_DEL, fd, nil)
+}
+
+func (e *epoll) Wait(timeout time.Duration) ([]net.Conn, error) {
+	msec := -1
+	if timeout >= 0 {
+		// round up so that short timeouts do not turn into busy polling
+		msec = int((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+
+	fallback := e.getFallbackIfAny()
+	if fallback != nil && fallback.pending() {
+		msec = 0
+	}
+
+	var n int
+	var err error
+	for {
+		n, err = unix.EpollWait(e.fd, e.events, msec)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conns := make([]net.Conn, 0, n)
+	e.mu.Lock()
+	for _, ev := range e.events[:n] {
+		if fallback != nil && int(ev.Fd) == e.wakefd {
+			var b [8]byte
+			unix.Read(e.wakefd, b[:])
+			continue
+		}
+		if conn, ok := e.conns[ev.Fd]; ok {
+			conns = append(conns, conn)
+		}
+	}
+	e.mu.Unlock()
+
+	if fallback != nil && len(conns) < len(e.events) {
+		conns = append(conns, fallback.ready(len(e.events)-len(conns))...)
+	}
+	return conns, nil
+}
+
+func (e *epoll) Close() error {
+	e.mu.Lock()
+	e.conns = make(map[int32]net.Conn)
+	fallback := e.fallback
+	e.mu.Unlock()
+
+	if fallback != nil {
+		fallback.Close()
+		unix.Close(e.wakefd)
+	}
+	return unix.Close(e.fd)
+}
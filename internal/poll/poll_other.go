@@ -0,0 +1,8 @@
+//go:build !linux
+
+package poll
+
+// New returns a goroutine based poller on platforms without epoll.
+func New(size int) (Poller, error) {
+	return NewGoroutinePoller(size), nil
+}
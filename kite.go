@@ -1,13 +1,20 @@
 package kite
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/smallnest/epoller"
 	"github.com/vderic/kite-client-go/client"
+	"github.com/vderic/kite-client-go/internal/bufpool"
+	"github.com/vderic/kite-client-go/internal/poll"
 	"github.com/vderic/kite-client-go/xrg"
+	"io"
+	"math/rand"
 	"net"
-	"syscall"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type FileSpec interface {
@@ -61,20 +68,60 @@ type Request struct {
 	Spec     FileSpec `json:"filespec"`
 }
 
+const (
+	minPollWait = time.Millisecond
+	maxPollWait = 100 * time.Millisecond
+)
+
+// ErrCanceled is returned by NextRow once the query was canceled.
+var ErrCanceled = errors.New("kite: query canceled")
+
 type KiteClient struct {
 	request Request
-	sss     map[int]client.SockStream
-	poller  *epoller.Epoll
-	pages   []xrg.Iterator
-	curr    *xrg.Iterator
-	hosts   []string
-	fragid  int
-	fragcnt int
+	// mu protects sss against Cancel, which reads it from another
+	// goroutine
+	mu         sync.Mutex
+	sss        map[net.Conn]*fragConn
+	canceled   atomic.Bool
+	ctx        context.Context // of the query, canceled by Cancel
+	stop       context.CancelFunc
+	poller     poll.Poller
+	pages      []xrg.Iterator
+	curr       *xrg.Iterator
+	hosts      []string
+	fragid     int
+	fragcnt    int
+	fragids    []int
+	sampling   bool
+	samplek    int
+	sampleseed int64
+	safe       bool
+	nocopy     bool
+	lazy       bool
+	nopool     bool
+	pollsize   int
+	timeout    time.Duration
+	projection []string
+	project    map[int]bool
+	maxfrags   int
+	maxperhost int
+	queue      []Request
+	running    []int
+	placement  Placement
+	health     *HealthTracker
+	connpool   *ConnPool
+	dialer     func(ctx context.Context, network, addr string) (net.Conn, error)
+	dialkey    string // tells the pooled connections of dialer apart
+	hedging    bool
+	hedgepct   float64
+	hedgefac   float64
+	hedgemin   int
+	durations  []time.Duration
 }
 
 func NewKiteClient() *KiteClient {
 	c := new(KiteClient)
-	c.sss = make(map[int]client.SockStream)
+	c.sss = make(map[net.Conn]*fragConn)
 	c.curr = nil
 	return c
 }
@@ -89,12 +136,78 @@ func (c *KiteClient) Sql(sql string) *KiteClient {
 	return c
 }
 
+// Fragment runs fragment fragid out of fragcnt, or all of them when fragid
+// is -1. It replaces the fragments set by an earlier Fragments or Sample.
 func (c *KiteClient) Fragment(fragid, fragcnt int) *KiteClient {
 	c.fragid = fragid
 	c.fragcnt = fragcnt
+	c.fragids = nil
+	c.sampling = false
+	return c
+}
+
+// Fragments runs the given fragment ids out of fragcnt, e.g. to re-run
+// failed fragments or to split a query across worker processes. It replaces
+// the fragments set by an earlier Fragment or Sample.
+func (c *KiteClient) Fragments(fragids []int, fragcnt int) *KiteClient {
+	c.fragid = -1
+	c.fragcnt = fragcnt
+	c.fragids = append([]int{}, fragids...)
+	c.sampling = false
+	return c
+}
+
+// Sample runs k fragments out of fragcnt chosen at random for a quick
+// preview of the data. The same seed picks the same fragments. It replaces
+// the fragments set by an earlier Fragment or Fragments.
+func (c *KiteClient) Sample(k, fragcnt int, seed int64) *KiteClient {
+	c.fragid = -1
+	c.fragcnt = fragcnt
+	c.fragids = nil
+	c.sampling = true
+	c.samplek = k
+	c.sampleseed = seed
 	return c
 }
 
+// fragmentIds returns the ids of the fragments to run.
+func (c *KiteClient) fragmentIds() ([]int, error) {
+	if c.sampling {
+		if c.samplek <= 0 || c.samplek > c.fragcnt {
+			return nil, fmt.Errorf("cannot sample %d out of %d fragments", c.samplek, c.fragcnt)
+		}
+		ids := rand.New(rand.NewSource(c.sampleseed)).Perm(c.fragcnt)[:c.samplek]
+		sort.Ints(ids)
+		return ids, nil
+	}
+
+	if c.fragids != nil {
+		if len(c.fragids) == 0 {
+			return nil, fmt.Errorf("empty fragment list")
+		}
+		seen := make(map[int]bool, len(c.fragids))
+		for _, id := range c.fragids {
+			if id < 0 || id >= c.fragcnt {
+				return nil, fmt.Errorf("fragment id %d out of range [0, %d)", id, c.fragcnt)
+			}
+			if seen[id] {
+				return nil, fmt.Errorf("duplicate fragment id %d", id)
+			}
+			seen[id] = true
+		}
+		return c.fragids, nil
+	}
+
+	if c.fragid == -1 {
+		ids := make([]int, c.fragcnt)
+		for i := range ids {
+			ids[i] = i
+		}
+		return ids, nil
+	}
+	return []int{c.fragid}, nil
+}
+
 func (c *KiteClient) FileSpec(fspec FileSpec) *KiteClient {
 	c.request.Spec = fspec
 	return c
@@ -105,61 +218,253 @@ func (c *KiteClient) Host(hosts []string) *KiteClient {
 	return c
 }
 
-func (c *KiteClient) getPage(sock client.SockStream) (p []xrg.Vector, err error) {
-	page := make([]xrg.Vector, 0)
-	for {
-		msg, err := sock.Recv()
-		if err != nil {
-			return nil, err
+// SafeMode validates every vector received from the server before it is
+// decoded. Corrupt vectors make NextRow return an error wrapping
+// xrg.ErrCorrupt.
+func (c *KiteClient) SafeMode(safe bool) *KiteClient {
+	c.safe = safe
+	return c
+}
+
+// ZeroCopy makes strings and arrays in the rows returned by NextRow alias
+// the received page instead of being copied. They are only valid until the
+// next page is read, unless the page is kept with it.Page().Retain().
+func (c *KiteClient) ZeroCopy(nocopy bool) *KiteClient {
+	c.nocopy = nocopy
+	return c
+}
+
+// Lazy stops NextRow from decoding every value of each row. A value is only
+// decoded when the column is read with it.Column or it.Get, and it.Value
+// holds nil for the columns not read yet.
+func (c *KiteClient) Lazy(lazy bool) *KiteClient {
+	c.lazy = lazy
+	return c
+}
+
+// PollerSize sets the maximum number of ready connections serviced per
+// wait. The default is 256.
+func (c *KiteClient) PollerSize(n int) *KiteClient {
+	c.pollsize = n
+	return c
+}
+
+// PollTimeout makes NextRow fail when no data arrives from any fragment
+// for d. The default 0 waits forever.
+func (c *KiteClient) PollTimeout(d time.Duration) *KiteClient {
+	c.timeout = d
+	return c
+}
+
+// BufferPool enables reusing the buffers of received messages and
+// decompressed vectors once their page is released. It is on by default.
+func (c *KiteClient) BufferPool(enabled bool) *KiteClient {
+	c.nopool = !enabled
+	return c
+}
+
+// Projection restricts decoding to the named schema columns. Vectors of
+// the other columns are not decompressed and their values are nil in the
+// rows returned by NextRow. A nil cols decodes every column.
+func (c *KiteClient) Projection(cols []string) *KiteClient {
+	c.projection = cols
+	return c
+}
+
+func (c *KiteClient) resolveProjection() error {
+	c.project = nil
+	if c.projection == nil {
+		return nil
+	}
+
+	c.project = make(map[int]bool, len(c.projection))
+	for _, name := range c.projection {
+		found := false
+		for i, col := range c.request.Schema {
+			if col.Name == name {
+				c.project[i] = true
+				found = true
+			}
 		}
+		if !found {
+			return fmt.Errorf("projected column %s not in schema", name)
+		}
+	}
+	return nil
+}
 
-		if msg.Msgty == client.KITE_MESSAGE_BYE {
-			return nil, nil
-		} else if msg.Msgty == client.KITE_MESSAGE_ERROR {
-			err = fmt.Errorf(string(msg.Buffer[0:msg.Msglen]))
-			return nil, err
-		} else if msg.Msgty == client.KITE_MESSAGE_VECTOR {
-			if msg.Msglen == 0 {
-				break
-			} else {
-				vec, err := xrg.NewVector(msg.Buffer)
-				if err != nil {
-					return nil, err
+// projected reports whether the vector in b belongs to a projected column.
+func (c *KiteClient) projected(b []byte) bool {
+	if c.project == nil || len(b) < xrg.XRG_HEADER_SIZE {
+		return true
+	}
+	var hdr xrg.VectorHeader
+	if hdr.Read(b[0:xrg.XRG_HEADER_SIZE]) != nil {
+		return true
+	}
+	return c.project[int(hdr.Fieldidx)]
+}
+
+// fragConn is the connection serving a fragment and the page being
+// assembled from its messages.
+type fragConn struct {
+	ss   *client.SockStream
+	conn net.Conn // as returned by the poller
+	raw  net.Conn // as dialed
+	host int
+	frag *fragment
+	page []xrg.Vector
+	bufs [][]byte
+	held []*xrg.Page // pages held back until the attempt finishes
+
+	// dirty is set when the connection is not at a message boundary after
+	// BYE_ and cannot be reused
+	dirty bool
+}
+
+// free returns the buffers of the partial page to the pool.
+func (fc *fragConn) free() {
+	for _, b := range fc.bufs {
+		bufpool.Put(b)
+	}
+	fc.bufs = nil
+	fc.page = nil
+}
+
+// discard frees the partial page and releases the held pages.
+func (fc *fragConn) discard() {
+	fc.free()
+	for _, p := range fc.held {
+		p.Release()
+	}
+	fc.held = nil
+}
+
+// readConn consumes the bytes available on the connection and returns the
+// pages they completed. bye is true once the server ended the stream.
+func (c *KiteClient) readConn(fc *fragConn) (pages []*xrg.Page, bye bool, err error) {
+	msgs, rerr := fc.ss.ReadAvailable(!c.nopool)
+	for i := range msgs {
+		if bye || err != nil {
+			fc.dirty = true
+			msgs[i].Free()
+			continue
+		}
+
+		var p *xrg.Page
+		p, bye, err = c.handleMessage(fc, msgs[i])
+		if p != nil {
+			pages = append(pages, p)
+		}
+	}
+
+	if bye && rerr != nil {
+		fc.dirty = true
+	}
+	if err == nil && !bye && rerr != nil {
+		if rerr == io.EOF {
+			err = fmt.Errorf("connection closed before end of data")
+		} else {
+			err = rerr
+		}
+	}
+	if err != nil {
+		fc.free()
+		for _, p := range pages {
+			p.Release()
+		}
+		return nil, false, err
+	}
+	return pages, bye, nil
+}
+
+// handleMessage adds a message to the page being assembled and returns the
+// page once its terminating empty vector arrives.
+func (c *KiteClient) handleMessage(fc *fragConn, msg client.KiteMessage) (p *xrg.Page, bye bool, err error) {
+	var alloc func(int) []byte
+	if !c.nopool {
+		alloc = bufpool.Get
+	}
+
+	if msg.Msgty == client.KITE_MESSAGE_BYE {
+		msg.Free()
+		fc.free()
+		return nil, true, nil
+	} else if msg.Msgty == client.KITE_MESSAGE_ERROR {
+		err = fmt.Errorf(string(msg.Buffer[0:msg.Msglen]))
+		msg.Free()
+		return nil, false, err
+	} else if msg.Msgty == client.KITE_MESSAGE_VECTOR {
+		if msg.Msglen == 0 {
+			msg.Free()
+			bufs := fc.bufs
+			free := func() {
+				for _, b := range bufs {
+					bufpool.Put(b)
 				}
-				page = append(page, vec)
 			}
+			if c.nopool {
+				free = nil
+			}
+			p = xrg.NewPage(fc.page, free)
+			fc.page = nil
+			fc.bufs = nil
+			return p, false, nil
+		}
+
+		if !c.nopool {
+			fc.bufs = append(fc.bufs, msg.Buffer)
+		}
+		var vec xrg.Vector
+		if !c.projected(msg.Buffer) {
+			err = vec.ReadFlags(msg.Buffer)
+			if err == nil && c.safe {
+				err = vec.Validate()
+			}
+		} else if c.safe {
+			err = vec.ReadSafeAlloc(msg.Buffer, alloc)
 		} else {
-			err = fmt.Errorf("Invalid kite message type")
-			return nil, err
+			err = vec.ReadAlloc(msg.Buffer, alloc)
+		}
+		if err != nil {
+			return nil, false, err
 		}
+		if vec.Header.Nbyte != vec.Header.Zbyte && alloc != nil {
+			fc.bufs = append(fc.bufs, vec.Data)
+		}
+		fc.page = append(fc.page, vec)
+		return nil, false, nil
 	}
 
-	p = page
-	return p, nil
+	msg.Free()
+	err = fmt.Errorf("Invalid kite message type")
+	return nil, false, err
 }
 
-func getFd(conn net.Conn) int {
-	if con, ok := conn.(syscall.Conn); ok {
-		raw, err := con.SyscallConn()
-		if err != nil {
-			return 0
-		}
-		sfd := 0
-		raw.Control(func(fd uintptr) {
-			sfd = int(fd)
-		})
-		return sfd
-	} else if con, ok := conn.(epoller.ConnImpl); ok {
-		return con.GetFD()
+// newIterator binds the page to the schema.
+func (c *KiteClient) newIterator(p *xrg.Page) (iter xrg.Iterator, err error) {
+	if c.safe {
+		iter, err = p.IteratorSafe()
+	} else {
+		iter = p.Iterator()
+	}
+	if err == nil {
+		err = c.bindColumns(&iter)
 	}
-	return 0
+	if err != nil {
+		p.Release()
+		return iter, err
+	}
+	iter.SetZeroCopy(c.nocopy)
+	iter.SetLazy(c.lazy)
+	return iter, nil
 }
 
 func (c *KiteClient) Submit() error {
 	var err error = nil
 	var requests []Request
 
-	c.curr = nil
+	c.releaseCurr()
 
 	if len(c.hosts) == 0 {
 		return fmt.Errorf("no host provided")
@@ -173,13 +478,18 @@ func (c *KiteClient) Submit() error {
 		return fmt.Errorf("invalid file spec")
 	}
 
+	err = c.resolveProjection()
+	if err != nil {
+		return err
+	}
+
 	if len(c.request.Schema) == 0 {
 		return fmt.Errorf("no schema found")
 	}
 
 	for _, col := range c.request.Schema {
 		if xrg.ValidateType(col.Type) == false {
-			return fmt.Errorf("invalid type in schema ", col)
+			return fmt.Errorf("invalid type in schema %v", col)
 		}
 
 		if col.Type == "decimal" || col.Type == "decimal[]" {
@@ -194,98 +504,142 @@ func (c *KiteClient) Submit() error {
 		return err
 	}
 
-	if c.fragid == -1 {
-		for i := 0; i < c.fragcnt; i++ {
-			req := c.request
-			req.Fragment = [2]int{i, c.fragcnt}
-			requests = append(requests, req)
-		}
-	} else {
-		c.request.Fragment = [2]int{c.fragid, c.fragcnt}
-		requests = append(requests, c.request)
-
+	fragids, err := c.fragmentIds()
+	if err != nil {
+		return err
+	}
+	for _, id := range fragids {
+		req := c.request
+		req.Fragment = [2]int{id, c.fragcnt}
+		requests = append(requests, req)
 	}
 
-	c.poller, err = epoller.NewPoller(1000000)
+	c.poller, err = poll.New(c.pollsize)
 	if err != nil {
 		return err
 	}
 
-	for i := 0; i < len(requests); i++ {
+	// a new query, undo a Cancel of the previous one
+	c.mu.Lock()
+	c.canceled.Store(false)
+	c.ctx, c.stop = context.WithCancel(context.Background())
+	c.mu.Unlock()
+	c.queue = requests
+	c.durations = nil
+	c.running = make([]int, len(c.hosts))
+	return c.dispatch()
+}
 
-		// JSON request
-		js, err := json.Marshal(requests[i])
-		if err != nil {
-			return err
+// bindColumns maps the Fieldidx of each vector back to the schema so that
+// rows can be accessed by column name.
+func (c *KiteClient) bindColumns(iter *xrg.Iterator) error {
+	schema := c.request.Schema
+	if iter.Nvec < len(schema) {
+		return fmt.Errorf("server returned %d columns, schema has %d", iter.Nvec, len(schema))
+	}
+
+	names := make([]string, iter.Nvec)
+	for i, hdr := range iter.Header {
+		idx := int(hdr.Fieldidx)
+		if idx < 0 || idx >= len(schema) {
+			return fmt.Errorf("column %d has field index %d out of schema range", i, idx)
 		}
 
-		n := i % len(c.hosts)
-		conn, err := net.Dial("tcp", c.hosts[n])
+		col := schema[idx]
+		ptyp, ltyp, err := xrg.ColumnType(col.Type, col.Precision)
 		if err != nil {
 			return err
 		}
-		fd := getFd(conn)
-
-		// add to epoll
-		ss := client.SockStream{conn}
-		c.sss[fd] = ss
-		c.poller.Add(conn)
-
-		// send message
-		ss.Send(client.KITE_MESSAGE_KIT1, nil)
-		ss.Send(client.KITE_MESSAGE_JSON, js)
+		if hdr.Ptyp != ptyp || hdr.Ltyp != ltyp {
+			return fmt.Errorf("column %s has type (%d, %d), schema type %s expects (%d, %d)",
+				col.Name, hdr.Ptyp, hdr.Ltyp, col.Type, ptyp, ltyp)
+		}
+		names[i] = col.Name
 	}
-
-	return nil
+	return iter.SetColumns(names)
 }
 
 func (c *KiteClient) nextPage() (it *xrg.Iterator, err error) {
 
-	var x xrg.Iterator
-	if len(c.pages) != 0 {
-		x, c.pages = c.pages[0], c.pages[1:]
-		return &x, nil
-	}
+	// service the ready connections until a page is complete. While idle
+	// the wait grows from minPollWait to maxPollWait.
+	wait := minPollWait
+	idle := time.Now()
+	for len(c.pages) == 0 && len(c.sss) > 0 {
+		if c.canceled.Load() {
+			return it, ErrCanceled
+		}
+		if c.hedging {
+			err = c.hedge()
+			if err != nil {
+				return it, err
+			}
+		}
 
-	for {
-		if len(c.sss) == 0 {
-			break
+		if c.timeout > 0 {
+			left := c.timeout - time.Since(idle)
+			if left <= 0 {
+				return it, fmt.Errorf("no data received for %v", c.timeout)
+			}
+			if wait > left {
+				wait = left
+			}
 		}
 
-		conns, err := c.poller.Wait(1)
+		conns, err := c.poller.Wait(wait)
 		if err != nil {
 			return it, err
 		}
+		if len(conns) == 0 {
+			wait *= 2
+			if wait > maxPollWait {
+				wait = maxPollWait
+			}
+			continue
+		}
+		wait = minPollWait
+		idle = time.Now()
 
 		for _, connection := range conns {
-			fd := getFd(connection)
-
-			ss, ok := c.sss[fd]
+			// the connection may have been closed as a hedging loser
+			fc, ok := c.sss[connection]
 			if !ok {
-				err = fmt.Errorf("sockstream not found.")
-				return it, err
+				continue
 			}
 
-			page, err := c.getPage(ss)
+			pages, bye, err := c.readConn(fc)
 			if err != nil {
-				/*
-					            if err == io.EOF || errors.Is(err, net.ErrClosed) {
-					                c.poller.Remove(sock.Conn)
-					            } else {
-								    return err
-					            }
-				*/
+				if c.canceled.Load() {
+					// Cancel closed the connection
+					return it, ErrCanceled
+				}
+				if c.health != nil {
+					c.health.ReportFailure(c.hosts[fc.host], err)
+				}
+				if c.otherAttempts(fc) {
+					// a hedged copy of the fragment is still running
+					c.drop(fc)
+					continue
+				}
 				return it, err
 			}
 
-			if page == nil {
-				c.poller.Remove(connection)
-				ss.Close()
-				delete(c.sss, fd)
-			} else {
-				// push to the list
-				iter := xrg.NewIterator(page)
-				c.pages = append(c.pages, iter)
+			if len(fc.frag.attempts) > 1 {
+				// hedged, the pages wait for the first copy to finish
+				fc.held = append(fc.held, pages...)
+			} else if len(pages) > 0 {
+				fc.frag.delivered = true
+				err = c.deliver(pages)
+				if err != nil {
+					return it, err
+				}
+			}
+
+			if bye {
+				err = c.finish(fc)
+				if err != nil {
+					return it, err
+				}
 			}
 		}
 	}
@@ -295,6 +649,7 @@ func (c *KiteClient) nextPage() (it *xrg.Iterator, err error) {
 		return nil, nil
 	}
 
+	var x xrg.Iterator
 	x, c.pages = c.pages[0], c.pages[1:]
 	return &x, nil
 }
@@ -302,23 +657,74 @@ func (c *KiteClient) nextPage() (it *xrg.Iterator, err error) {
 func (c *KiteClient) NextRow() (*xrg.Iterator, error) {
 	var err error = nil
 
-	if c.curr != nil && c.curr.Next() {
-		return c.curr, err
-	}
+	for {
+		if c.canceled.Load() {
+			return nil, ErrCanceled
+		}
+		if c.curr != nil {
+			if c.curr.Next() {
+				return c.curr, err
+			}
+			if err = c.curr.Err(); err != nil {
+				return nil, err
+			}
+		}
 
-	c.curr, err = c.nextPage()
-	if err != nil {
-		return nil, err
+		c.releaseCurr()
+		c.curr, err = c.nextPage()
+		if err != nil {
+			return nil, err
+		}
+		if c.curr == nil {
+			return nil, err
+		}
 	}
-	if c.curr != nil && c.curr.Next() {
-		return c.curr, err
+}
+
+func (c *KiteClient) releaseCurr() {
+	if c.curr != nil && c.curr.Page() != nil {
+		c.curr.Page().Release()
 	}
-	return nil, err
+	c.curr = nil
 }
 
 func (c *KiteClient) Close() {
-	c.poller.Close(false)
-	for _, ss := range c.sss {
-		ss.Close()
+	c.releaseCurr()
+	for _, iter := range c.pages {
+		iter.Page().Release()
+	}
+	c.pages = nil
+	if c.poller != nil {
+		c.poller.Close()
+	}
+	c.mu.Lock()
+	for _, fc := range c.sss {
+		fc.ss.Close()
+		fc.discard()
+	}
+	c.sss = make(map[net.Conn]*fragConn)
+	if c.stop != nil {
+		c.stop()
+	}
+	c.mu.Unlock()
+	c.queue = nil
+}
+
+// Cancel stops the query. It may be called from another goroutine, e.g.
+// while NextRow is blocked. Pending dials are aborted, the servers are told
+// to stop scanning before the connections are closed, and NextRow returns
+// ErrCanceled from then on. Close must still be called, after which the
+// client can run another query with Submit.
+func (c *KiteClient) Cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.canceled.Swap(true) {
+		return
+	}
+	if c.stop != nil {
+		c.stop()
+	}
+	for _, fc := range c.sss {
+		fc.ss.Cancel()
 	}
 }
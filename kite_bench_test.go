@@ -0,0 +1,104 @@
+package kite
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/vderic/kite-client-go/kitetest"
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+var benchSchema = []Coldef{
+	{Name: "id", Type: "int64"},
+	{Name: "price", Type: "double"},
+	{Name: "name", Type: "string"},
+}
+
+// benchPages builds npage pages of nrow rows matching benchSchema.
+func benchPages(b *testing.B, npage, nrow int) [][][]byte {
+	pages := make([][][]byte, npage)
+	for p := range pages {
+		id := xrg.NewVectorBuilder(xrg.XRG_PTYP_INT64, xrg.XRG_LTYP_NONE, 0, 0, 0)
+		price := xrg.NewVectorBuilder(xrg.XRG_PTYP_FP64, xrg.XRG_LTYP_NONE, 1, 0, 0)
+		name := xrg.NewVectorBuilder(xrg.XRG_PTYP_BYTEA, xrg.XRG_LTYP_STRING, 2, 0, 0)
+		for r := 0; r < nrow; r++ {
+			n := int64(p*nrow + r)
+			for _, err := range []error{id.Append(n), price.Append(float64(n%1000) / 4), name.Append(fmt.Sprintf("item-%d", n%5000))} {
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		for _, vb := range []*xrg.VectorBuilder{id, price, name} {
+			v, err := vb.Bytes(true)
+			if err != nil {
+				b.Fatal(err)
+			}
+			pages[p] = append(pages[p], v)
+		}
+	}
+	return pages
+}
+
+// benchScan scans frags fragments of npage pages of nrow rows served by
+// nhost mock servers, with the client set up by configure.
+func benchScan(b *testing.B, frags, nhost, npage, nrow int, configure func(*KiteClient)) {
+	pages := benchPages(b, npage, nrow)
+	var hosts []string
+	for i := 0; i < nhost; i++ {
+		srv, err := kitetest.NewServer("", pages)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer srv.Close()
+		hosts = append(hosts, srv.Addr)
+	}
+
+	want := frags * npage * nrow
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// lazy to measure the transport, not the boxing of values
+		c := NewKiteClient().Host(hosts).Schema(benchSchema).Sql("SELECT * FROM bench*").
+			Fragment(-1, frags).FileSpec(NewCsvFileSpec(",", "\"", "\"", "", false)).Lazy(true)
+		configure(c)
+		err := c.Submit()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		n := 0
+		for {
+			it, err := c.NextRow()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if it == nil {
+				break
+			}
+			n++
+		}
+		c.Close()
+		if n != want {
+			b.Fatalf("scanned %d rows, want %d", n, want)
+		}
+	}
+}
+
+func BenchmarkBufferPool(b *testing.B) {
+	b.Run("unpooled", func(b *testing.B) {
+		benchScan(b, 16, 4, 8, 4096, func(c *KiteClient) { c.BufferPool(false) })
+	})
+	b.Run("pooled", func(b *testing.B) {
+		benchScan(b, 16, 4, 8, 4096, func(c *KiteClient) { c.BufferPool(true) })
+	})
+}
+
+func BenchmarkPoll256Fragments(b *testing.B) {
+	b.Run("pollsize=1", func(b *testing.B) {
+		benchScan(b, 256, 4, 4, 1024, func(c *KiteClient) { c.PollerSize(1) })
+	})
+	b.Run("pollsize=default", func(b *testing.B) {
+		benchScan(b, 256, 4, 4, 1024, func(c *KiteClient) {})
+	})
+}
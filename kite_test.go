@@ -0,0 +1,488 @@
+package kite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vderic/kite-client-go/kitetest"
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+// startServer starts a mock server answering every request with the rows
+// of test/data/gpdb0_0.xrg and returns the schema of the file.
+func startServer(t testing.TB, configure func(*kitetest.Server)) (*kitetest.Server, []Coldef) {
+	t.Helper()
+	f, err := xrg.ReadFile("test/data/gpdb0_0.xrg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := kitetest.PagesFromFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bv, err := os.ReadFile("test/data/gpdb0.schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema []Coldef
+	err = json.Unmarshal(bv, &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := kitetest.NewUnstartedServer(pages)
+	if configure != nil {
+		configure(srv)
+	}
+	err = srv.Start("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv, schema
+}
+
+func newTestClient(srv *kitetest.Server, schema []Coldef, fragcnt int) *KiteClient {
+	return NewKiteClient().Host([]string{srv.Addr}).Schema(schema).Sql("select *").
+		Fragment(-1, fragcnt).FileSpec(NewCsvFileSpec(",", "\"", "\"", "", false))
+}
+
+func TestFragmentIds(t *testing.T) {
+	tests := []struct {
+		name string
+		cli  *KiteClient
+		want []int
+		err  bool
+	}{
+		{"all", NewKiteClient().Fragment(-1, 3), []int{0, 1, 2}, false},
+		{"one", NewKiteClient().Fragment(1, 3), []int{1}, false},
+		{"list", NewKiteClient().Fragments([]int{2, 0}, 3), []int{2, 0}, false},
+		{"list out of range", NewKiteClient().Fragments([]int{3}, 3), nil, true},
+		{"list duplicate", NewKiteClient().Fragments([]int{1, 1}, 3), nil, true},
+		{"sample all", NewKiteClient().Sample(3, 3, 1), []int{0, 1, 2}, false},
+		{"sample zero", NewKiteClient().Sample(0, 3, 1), nil, true},
+		{"sample negative", NewKiteClient().Sample(-1, 3, 1), nil, true},
+		{"sample too many", NewKiteClient().Sample(4, 3, 1), nil, true},
+		{"fragment after sample", NewKiteClient().Sample(1, 3, 1).Fragment(2, 3), []int{2}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.cli.fragmentIds()
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	a, _ := NewKiteClient().Sample(5, 100, 42).fragmentIds()
+	b, _ := NewKiteClient().Sample(5, 100, 42).fragmentIds()
+	if len(a) != 5 || !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed sampled %v and %v", a, b)
+	}
+}
+
+func TestLazy(t *testing.T) {
+	srv, schema := startServer(t, nil)
+
+	eager := newTestClient(srv, schema, 1)
+	lazy := newTestClient(srv, schema, 1).Lazy(true)
+	for _, c := range []*KiteClient{eager, lazy} {
+		err := c.Submit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+
+	n := 0
+	for {
+		want, err := eager.NextRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		it, err := lazy.NextRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil || it == nil {
+			if want != it {
+				t.Fatalf("row %d: lazy client ended with %v, eager with %v", n, it, want)
+			}
+			break
+		}
+
+		for i := 0; i < it.Nvec; i++ {
+			if it.Value[i] != nil {
+				t.Fatalf("row %d: column %d decoded before it was read", n, i)
+			}
+			if want.Flag[i] != 0 {
+				continue
+			}
+			v, err := it.Column(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, want.Value[i]) {
+				t.Fatalf("row %d column %d: got %v, want %v", n, i, v, want.Value[i])
+			}
+		}
+		n++
+	}
+	if n == 0 {
+		t.Fatal("no rows")
+	}
+}
+
+func scanAll(t *testing.T, c *KiteClient) int {
+	t.Helper()
+	err := c.Submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	n := 0
+	for {
+		it, err := c.NextRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if it == nil {
+			return n
+		}
+		n++
+	}
+}
+
+func TestHedge(t *testing.T) {
+	fast, schema := startServer(t, func(s *kitetest.Server) {
+		s.Pages = append(s.Pages, s.Pages...)
+	})
+	slow, _ := startServer(t, func(s *kitetest.Server) {
+		s.Pages = append(s.Pages, s.Pages...)
+		// long enough to be hedged before the first page
+		s.PageDelay = 2 * time.Second
+	})
+	perfrag := scanAll(t, newTestClient(fast, schema, 1))
+
+	c := newTestClient(fast, schema, 8).Hedge(50, 2, 2)
+	c.Host([]string{fast.Addr, slow.Addr})
+	n := scanAll(t, c)
+	if n != 8*perfrag {
+		t.Errorf("got %d rows, want %d", n, 8*perfrag)
+	}
+	// the fragments of the slow host are hedged on the fast one, beside
+	// the first scan and its own 4 fragments
+	if got := len(fast.Requests()) - 1; got != 8 {
+		t.Errorf("fast host got %d requests, want its 4 fragments and 4 hedged ones", got)
+	}
+}
+
+func TestHedgeStreamsUnhedged(t *testing.T) {
+	// a single fragment cannot be hedged, its pages must not wait for BYE_
+	srv, schema := startServer(t, func(s *kitetest.Server) {
+		s.Pages = append(s.Pages, s.Pages...)
+		s.PageDelay = 300 * time.Millisecond
+	})
+	c := newTestClient(srv, schema, 1).Hedge(50, 2, 1)
+	err := c.Submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	it, err := c.NextRow()
+	if err != nil || it == nil {
+		t.Fatalf("NextRow: %v, %v", it, err)
+	}
+	if d := time.Since(start); d >= 600*time.Millisecond {
+		t.Errorf("first row after %v, want it before the fragment finishes", d)
+	}
+}
+
+func TestCancelDial(t *testing.T) {
+	srv, schema := startServer(t, nil)
+
+	dialing := make(chan struct{})
+	c := newTestClient(srv, schema, 1)
+	c.Dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// a proxy that never answers
+		close(dialing)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	go func() {
+		<-dialing
+		c.Cancel()
+	}()
+	defer c.Close()
+
+	err := c.Submit()
+	if !errors.Is(err, ErrCanceled) {
+		t.Fatalf("Submit: got %v, want ErrCanceled", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	srv, schema := startServer(t, func(s *kitetest.Server) {
+		s.PageDelay = time.Second
+	})
+
+	c := newTestClient(srv, schema, 4)
+	err := c.Submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// NextRow is blocked until the first page, cancel before it arrives
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		c.Cancel()
+	}()
+	it, err := c.NextRow()
+	if !errors.Is(err, ErrCanceled) {
+		t.Fatalf("NextRow: got %v, %v, want ErrCanceled", it, err)
+	}
+
+	// the server sees BYE_ before the connection is closed
+	deadline := time.Now().Add(time.Second)
+	for srv.Cancels() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if srv.Cancels() == 0 {
+		t.Error("server did not receive the cancellation")
+	}
+}
+
+func TestSubmitAfterCancel(t *testing.T) {
+	srv, schema := startServer(t, nil)
+	want := scanAll(t, newTestClient(srv, schema, 1))
+
+	c := newTestClient(srv, schema, 1)
+	err := c.Submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Cancel()
+	_, err = c.NextRow()
+	if !errors.Is(err, ErrCanceled) {
+		t.Fatalf("NextRow: got %v, want ErrCanceled", err)
+	}
+	c.Close()
+
+	n := scanAll(t, c)
+	if n != want {
+		t.Errorf("got %d rows after Cancel, want %d", n, want)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	srv, schema := startServer(t, nil)
+	c := newTestClient(srv, schema, 1)
+	err := c.Submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	it, err := c.NextRow()
+	if err != nil || it == nil {
+		t.Fatalf("NextRow: %v, %v", it, err)
+	}
+	names := make([]string, len(schema))
+	for i, col := range schema {
+		names[i] = col.Name
+	}
+	if !reflect.DeepEqual(it.Columns(), names) {
+		t.Fatalf("got columns %v, want %v", it.Columns(), names)
+	}
+
+	for i, name := range names {
+		if it.Index(name) != i {
+			t.Errorf("Index(%s) = %d, want %d", name, it.Index(name), i)
+		}
+		got, err := it.Get(name)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", name, err)
+		}
+		var want any
+		if it.Flag[i]&xrg.XRG_FLAG_NULL == 0 {
+			want, err = it.Column(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%s) = %v, want %v", name, got, want)
+		}
+	}
+	if it.Index("nosuch") != -1 {
+		t.Errorf("Index(nosuch) = %d", it.Index("nosuch"))
+	}
+	if _, err := it.Get("nosuch"); err == nil {
+		t.Error("Get(nosuch): expected error")
+	}
+}
+
+func TestColumnsMismatch(t *testing.T) {
+	// the server drops the last column
+	srv, schema := startServer(t, func(s *kitetest.Server) {
+		for i, page := range s.Pages {
+			s.Pages[i] = page[:len(page)-1]
+		}
+	})
+	c := newTestClient(srv, schema, 1)
+	err := c.Submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.NextRow()
+	if err == nil || !strings.Contains(err.Error(), "schema has") {
+		t.Errorf("too few columns: got %v", err)
+	}
+
+	srv, schema = startServer(t, nil)
+	wrong := append([]Coldef(nil), schema...)
+	for i, col := range wrong {
+		if col.Type == "int32" {
+			wrong[i].Type = "int64"
+		}
+	}
+	c = newTestClient(srv, wrong, 1)
+	err = c.Submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.NextRow()
+	if err == nil || !strings.Contains(err.Error(), "schema type int64") {
+		t.Errorf("type mismatch: got %v", err)
+	}
+}
+
+// stringArrayPages returns npage pages of a string and an int32[] column
+// whose values differ from page to page.
+func stringArrayPages(t *testing.T, npage, nrow int) ([][][]byte, []Coldef) {
+	t.Helper()
+	var pages [][][]byte
+	for p := 0; p < npage; p++ {
+		s := xrg.NewVectorBuilder(xrg.XRG_PTYP_BYTEA, xrg.XRG_LTYP_STRING, 0, 0, 0)
+		a := xrg.NewVectorBuilder(xrg.XRG_PTYP_BYTEA, xrg.XRG_LTYP_ARRAY, 1, 0, 0)
+		for r := 0; r < nrow; r++ {
+			err := s.Append(pageString(p, r))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = a.Append(pageArray(p, r))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		var page [][]byte
+		for _, b := range []*xrg.VectorBuilder{s, a} {
+			vec, err := b.Bytes(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page = append(page, vec)
+		}
+		pages = append(pages, page)
+	}
+	return pages, []Coldef{{Name: "s", Type: "string"}, {Name: "a", Type: "int32[]"}}
+}
+
+func pageString(p, r int) string {
+	return fmt.Sprintf("page %d row %d", p, r)
+}
+
+func pageArray(p, r int) xrg.ArrayType {
+	return xrg.ArrayType{
+		Header: xrg.ArrayHeader{Ptyp: xrg.XRG_PTYP_INT32, Ltyp: xrg.XRG_LTYP_NONE},
+		Dims:   []int32{3},
+		Lbs:    []int32{1},
+		Values: []any{int32(p), nil, int32(r)},
+	}
+}
+
+// TestPageReuse keeps the values of the first page while the pooled
+// buffers behind it are reused for the following pages.
+func TestPageReuse(t *testing.T) {
+	const npage, nrow = 4, 50
+	for _, nocopy := range []bool{false, true} {
+		var schema []Coldef
+		srv, _ := startServer(t, func(s *kitetest.Server) {
+			s.Pages, schema = stringArrayPages(t, npage, nrow)
+			// each page is read after the previous one is released
+			s.PageDelay = 10 * time.Millisecond
+		})
+		c := newTestClient(srv, schema, 1).BufferPool(true).ZeroCopy(nocopy)
+		err := c.Submit()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var first *xrg.Page
+		var strs []any
+		var arrs []any
+		n := 0
+		for {
+			it, err := c.NextRow()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if it == nil {
+				break
+			}
+			if first == nil {
+				first = it.Page()
+				if nocopy {
+					// zero-copy values are only kept with the page
+					first.Retain()
+				}
+			}
+			if it.Page() == first {
+				s, err := it.Get("s")
+				if err != nil {
+					t.Fatal(err)
+				}
+				a, err := it.Get("a")
+				if err != nil {
+					t.Fatal(err)
+				}
+				strs = append(strs, s)
+				arrs = append(arrs, a)
+			}
+			n++
+		}
+		c.Close()
+
+		if n != npage*nrow || len(strs) != nrow {
+			t.Fatalf("nocopy %v: got %d rows, %d on the first page", nocopy, n, len(strs))
+		}
+		for r := range strs {
+			if strs[r] != pageString(0, r) {
+				t.Errorf("nocopy %v: row %d: got %q, want %q", nocopy, r, strs[r], pageString(0, r))
+			}
+			arr, ok := arrs[r].(xrg.ArrayType)
+			if want := pageArray(0, r).Values; !ok || !reflect.DeepEqual(arr.Values, want) {
+				t.Errorf("nocopy %v: row %d: got %v, want %v", nocopy, r, arrs[r], want)
+			}
+		}
+		if nocopy {
+			first.Release()
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Package kitetest provides a mock kite server for tests and local
+// development of kite clients.
+package kitetest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vderic/kite-client-go/client"
+	"github.com/vderic/kite-client-go/xrg"
+)
+
+// Server answers every request with the same pages of vectors followed by
+// BYE_. Each page is a list of raw vectors as returned by xrg.File.Raw.
+type Server struct {
+	Addr      string
+	Pages     [][][]byte
+	PageDelay time.Duration
+	// KeepAlive serves further requests on a connection after BYE_
+	// instead of closing it.
+	KeepAlive bool
+
+	ln        net.Listener
+	mu        sync.Mutex
+	conns     map[net.Conn]bool
+	requests  [][]byte
+	nconn     int
+	ncancel   int
+	nactive   int
+	maxactive int
+	wg        sync.WaitGroup
+}
+
+var errCanceled = errors.New("request canceled by the client")
+
+// NewServer starts a server listening on addr. An empty addr picks a free
+// port on localhost.
+func NewServer(addr string, pages [][][]byte) (*Server, error) {
+	s := NewUnstartedServer(pages)
+	err := s.Start(addr)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewUnstartedServer returns a server that can be configured before Start.
+func NewUnstartedServer(pages [][][]byte) *Server {
+	return &Server{Pages: pages, conns: make(map[net.Conn]bool)}
+}
+
+// Start listens on addr, a host:port or unix:///path/to/socket.
+func (s *Server) Start(addr string) error {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix://") {
+		network = "unix"
+		addr = strings.TrimPrefix(addr, "unix://")
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+
+	s.ln = ln
+	s.Addr = ln.Addr().String()
+	if network == "unix" {
+		s.Addr = "unix://" + s.Addr
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return nil
+}
+
+// PagesFromFile returns a single page holding every vector of the file.
+func PagesFromFile(f *xrg.File) ([][][]byte, error) {
+	page := make([][]byte, f.Nvec())
+	for i := range page {
+		b, err := f.Raw(i)
+		if err != nil {
+			return nil, err
+		}
+		page[i] = b
+	}
+	return [][][]byte{page}, nil
+}
+
+// Conns returns the number of connections accepted so far.
+func (s *Server) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nconn
+}
+
+// MaxActive returns the largest number of requests served at the same time.
+func (s *Server) MaxActive() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxactive
+}
+
+// Cancels returns the number of requests the clients canceled with BYE_
+// while they were being served.
+func (s *Server) Cancels() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ncancel
+}
+
+// Requests returns the JSON requests received so far.
+func (s *Server) Requests() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.requests...)
+}
+
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.nconn++
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (s *Server) serve(conn net.Conn) error {
+	ss := client.SockStream{Conn: conn}
+
+	// messages are read by their own goroutine so that a cancellation is
+	// seen while the pages are sent
+	msgs := make(chan client.KiteMessage)
+	done := make(chan struct{})
+	defer close(done)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(msgs)
+		for {
+			msg, err := ss.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		err := s.serveRequest(&ss, msgs)
+		if err != nil || !s.KeepAlive {
+			return err
+		}
+	}
+}
+
+func (s *Server) serveRequest(ss *client.SockStream, msgs <-chan client.KiteMessage) error {
+	msg, ok := <-msgs
+	if !ok {
+		return io.EOF
+	}
+	if msg.Msgty != client.KITE_MESSAGE_KIT1 {
+		return s.fail(ss, "expected KIT1 message")
+	}
+
+	msg, ok = <-msgs
+	if !ok {
+		return io.EOF
+	}
+	if msg.Msgty != client.KITE_MESSAGE_JSON {
+		return s.fail(ss, "expected JSON message")
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, msg.Buffer)
+	s.nactive++
+	if s.nactive > s.maxactive {
+		s.maxactive = s.nactive
+	}
+	s.mu.Unlock()
+
+	// the request stops being active before BYE_ lets the client send the
+	// next one
+	active := true
+	finish := func() {
+		if active {
+			active = false
+			s.mu.Lock()
+			s.nactive--
+			s.mu.Unlock()
+		}
+	}
+	defer finish()
+
+	var err error
+	for _, page := range s.Pages {
+		if s.PageDelay > 0 {
+			t := time.NewTimer(s.PageDelay)
+			select {
+			case msg, ok := <-msgs:
+				t.Stop()
+				return s.interrupt(msg, ok)
+			case <-t.C:
+			}
+		}
+		for _, vec := range page {
+			select {
+			case msg, ok := <-msgs:
+				return s.interrupt(msg, ok)
+			default:
+			}
+			err = ss.Send(client.KITE_MESSAGE_VECTOR, vec)
+			if err != nil {
+				return err
+			}
+		}
+		err = ss.Send(client.KITE_MESSAGE_VECTOR, nil)
+		if err != nil {
+			return err
+		}
+	}
+	finish()
+	return ss.Send(client.KITE_MESSAGE_BYE, nil)
+}
+
+// interrupt handles a message received while a request is served, ok is
+// false when the connection was closed.
+func (s *Server) interrupt(msg client.KiteMessage, ok bool) error {
+	if !ok {
+		return io.EOF
+	}
+	if msg.Msgty == client.KITE_MESSAGE_BYE {
+		s.mu.Lock()
+		s.ncancel++
+		s.mu.Unlock()
+		return errCanceled
+	}
+	return fmt.Errorf("unexpected %s message", msg.Msgty[:])
+}
+
+func (s *Server) fail(ss *client.SockStream, errmsg string) error {
+	ss.Send(client.KITE_MESSAGE_ERROR, []byte(errmsg))
+	return fmt.Errorf(errmsg)
+}
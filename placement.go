@@ -0,0 +1,200 @@
+package kite
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// HostLoad describes a host to a Placement.
+type HostLoad struct {
+	Addr      string
+	Running   int  // fragments in flight on the host
+	Available bool // false when the host is at its concurrency limit
+}
+
+// Placement chooses the host running each fragment. Place returns the index
+// in hosts of the host for fragment fragid, or -1 if no available host can
+// take it now. The same fragid and loads must give the same host so that
+// placement is reproducible. Place may be called concurrently by clients
+// sharing a Placement.
+type Placement interface {
+	Place(fragid int, hosts []HostLoad) int
+}
+
+// RoundRobin places fragment i on host i % len(hosts), or on the next
+// available host after it.
+func RoundRobin() Placement {
+	return roundRobin{}
+}
+
+type roundRobin struct{}
+
+func (roundRobin) Place(fragid int, hosts []HostLoad) int {
+	for i := range hosts {
+		h := (fragid + i) % len(hosts)
+		if hosts[h].Available {
+			return h
+		}
+	}
+	return -1
+}
+
+// Weighted spreads fragments over the hosts in proportion to weights, one
+// weight per host in the order given to KiteClient.Host. Hosts with a
+// weight <= 0 get no fragments.
+func Weighted(weights []int) Placement {
+	// smooth weighted round-robin so that the heavy hosts are interleaved
+	// with the light ones
+	total := 0
+	for _, w := range weights {
+		if w > 0 {
+			total += w
+		}
+	}
+	seq := make([]int, 0, total)
+	curr := make([]int, len(weights))
+	for len(seq) < total {
+		best := -1
+		for h, w := range weights {
+			if w <= 0 {
+				continue
+			}
+			curr[h] += w
+			if best < 0 || curr[h] > curr[best] {
+				best = h
+			}
+		}
+		curr[best] -= total
+		seq = append(seq, best)
+	}
+	return &weighted{seq: seq}
+}
+
+type weighted struct {
+	seq []int
+}
+
+func (p *weighted) Place(fragid int, hosts []HostLoad) int {
+	for i := range p.seq {
+		h := p.seq[(fragid+i)%len(p.seq)]
+		if h < len(hosts) && hosts[h].Available {
+			return h
+		}
+	}
+	return -1
+}
+
+// LeastLoaded places each fragment on the available host with the fewest
+// fragments in flight. Ties go to the host RoundRobin would pick.
+func LeastLoaded() Placement {
+	return leastLoaded{}
+}
+
+type leastLoaded struct{}
+
+func (leastLoaded) Place(fragid int, hosts []HostLoad) int {
+	best := -1
+	for i := range hosts {
+		h := (fragid + i) % len(hosts)
+		if hosts[h].Available && (best < 0 || hosts[h].Running < hosts[best].Running) {
+			best = h
+		}
+	}
+	return best
+}
+
+// ConsistentHash maps fragment ids onto a hash ring of the host addresses
+// with replicas virtual nodes per host. A fragment keeps its host across
+// queries and only the fragments of a host that is added or removed move.
+func ConsistentHash(replicas int) Placement {
+	if replicas <= 0 {
+		replicas = 100
+	}
+	return &consistentHash{replicas: replicas}
+}
+
+type ringNode struct {
+	hash uint32
+	addr string
+}
+
+type consistentHash struct {
+	replicas int
+
+	// the ring of the last host list, shared by the clients using p
+	mu    sync.Mutex
+	addrs []string
+	ring  []ringNode
+}
+
+func hash32(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+
+	// fnv alone spreads short keys like fragment ids poorly, finish with
+	// the murmur3 mixer
+	x := h.Sum32()
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
+
+// getRing returns the ring of hosts, rebuilding it when the host list
+// differs from the last call. A returned ring is never modified.
+func (p *consistentHash) getRing(hosts []HostLoad) []ringNode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := len(p.addrs) != len(hosts)
+	for i := 0; !changed && i < len(hosts); i++ {
+		changed = p.addrs[i] != hosts[i].Addr
+	}
+	if !changed {
+		return p.ring
+	}
+
+	addrs := make([]string, 0, len(hosts))
+	ring := make([]ringNode, 0, len(hosts)*p.replicas)
+	for _, host := range hosts {
+		addrs = append(addrs, host.Addr)
+		for r := 0; r < p.replicas; r++ {
+			ring = append(ring, ringNode{hash32(host.Addr + "#" + strconv.Itoa(r)), host.Addr})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash == ring[j].hash {
+			return ring[i].addr < ring[j].addr
+		}
+		return ring[i].hash < ring[j].hash
+	})
+	p.addrs = addrs
+	p.ring = ring
+	return ring
+}
+
+func (p *consistentHash) Place(fragid int, hosts []HostLoad) int {
+	if len(hosts) == 0 {
+		return -1
+	}
+
+	ring := p.getRing(hosts)
+	index := make(map[string]int, len(hosts))
+	for i, host := range hosts {
+		index[host.Addr] = i
+	}
+
+	key := hash32(strconv.Itoa(fragid))
+	start := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= key })
+	for i := 0; i < len(ring); i++ {
+		h := index[ring[(start+i)%len(ring)].addr]
+		if hosts[h].Available {
+			return h
+		}
+	}
+	return -1
+}
@@ -0,0 +1,46 @@
+package kite
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestConsistentHashShared(t *testing.T) {
+	// clients with different host lists share one placement
+	var hosts [][]HostLoad
+	var want [][]int
+	for c := 0; c < 4; c++ {
+		h := make([]HostLoad, 2+c)
+		for i := range h {
+			h[i] = HostLoad{Addr: "host" + strconv.Itoa(i) + ":7878", Available: true}
+		}
+		w := make([]int, 64)
+		for fragid := range w {
+			w[fragid] = ConsistentHash(0).Place(fragid, h)
+		}
+		hosts = append(hosts, h)
+		want = append(want, w)
+	}
+
+	p := ConsistentHash(0)
+	var wg sync.WaitGroup
+	for c := range hosts {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for n := 0; n < 4; n++ {
+				for fragid, h := range want[c] {
+					runtime.Gosched()
+					got := p.Place(fragid, hosts[c])
+					if got != h {
+						t.Errorf("%d hosts: fragment %d placed on %d, want %d", len(hosts[c]), fragid, got, h)
+						return
+					}
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+}
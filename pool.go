@@ -0,0 +1,184 @@
+package kite
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConnPool keeps the connections of finished fragments open for reuse by
+// later queries to the same host. Only use it with kite servers that serve
+// more than one request per connection. A pool can be shared by any number
+// of KiteClients.
+type ConnPool struct {
+	mu          sync.Mutex
+	idle        map[string][]idleConn
+	maxidle     int
+	idletimeout time.Duration
+	stats       PoolStats
+	closed      bool
+}
+
+type idleConn struct {
+	conn  net.Conn
+	since time.Time
+}
+
+// PoolStats counts the connections handled by a ConnPool.
+type PoolStats struct {
+	Dials   int // connections dialed because none was idle
+	Reuses  int // idle connections handed out again
+	Idle    int // connections currently idle
+	Evicted int // idle connections closed for timeout, limit or failure
+}
+
+func NewConnPool() *ConnPool {
+	p := new(ConnPool)
+	p.idle = make(map[string][]idleConn)
+	p.maxidle = 4
+	p.idletimeout = time.Minute
+	return p
+}
+
+// MaxIdlePerHost sets the number of idle connections kept per host.
+func (p *ConnPool) MaxIdlePerHost(n int) *ConnPool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxidle = n
+	return p
+}
+
+// IdleTimeout sets how long a connection may stay idle before it is closed.
+func (p *ConnPool) IdleTimeout(d time.Duration) *ConnPool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idletimeout = d
+	return p
+}
+
+// get returns an idle connection for key, the address of the host as
+// given by KiteClient.poolKey, or dials a new one.
+func (p *ConnPool) get(key string, dial func() (net.Conn, error)) (net.Conn, error) {
+	for {
+		p.mu.Lock()
+		p.expire()
+		conns := p.idle[key]
+		if len(conns) == 0 {
+			p.stats.Dials++
+			p.mu.Unlock()
+			return dial()
+		}
+		ic := conns[len(conns)-1]
+		p.idle[key] = conns[:len(conns)-1]
+		p.stats.Idle--
+		p.mu.Unlock()
+
+		if alive(ic.conn) {
+			p.mu.Lock()
+			p.stats.Reuses++
+			p.mu.Unlock()
+			return ic.conn, nil
+		}
+		ic.conn.Close()
+		p.mu.Lock()
+		p.stats.Evicted++
+		p.mu.Unlock()
+	}
+}
+
+// alive checks that the server did not close an idle connection or send
+// unexpected data on it.
+func alive(conn net.Conn) bool {
+	if sc, ok := conn.(syscall.Conn); ok {
+		raw, err := sc.SyscallConn()
+		if err != nil {
+			return false
+		}
+		res, supported := false, false
+		err = raw.Read(func(fd uintptr) bool {
+			res, supported = peekIdle(fd)
+			return true
+		})
+		if err != nil {
+			return false
+		}
+		if supported {
+			return res
+		}
+	}
+
+	// without a non-blocking peek, wait briefly for an EOF
+	err := conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	if err != nil {
+		return false
+	}
+	var b [1]byte
+	_, err = conn.Read(b[:])
+	conn.SetReadDeadline(time.Time{})
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// put returns a connection at a clean message boundary to the pool.
+func (p *ConnPool) put(key string, conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expire()
+	if p.closed || len(p.idle[key]) >= p.maxidle {
+		conn.Close()
+		p.stats.Evicted++
+		return
+	}
+	p.idle[key] = append(p.idle[key], idleConn{conn, time.Now()})
+	p.stats.Idle++
+}
+
+// expire closes the connections idle for longer than the idle timeout.
+func (p *ConnPool) expire() {
+	if p.idletimeout <= 0 {
+		return
+	}
+	deadline := time.Now().Add(-p.idletimeout)
+	for addr, conns := range p.idle {
+		n := 0
+		for _, ic := range conns {
+			if ic.since.Before(deadline) {
+				ic.conn.Close()
+				p.stats.Evicted++
+				p.stats.Idle--
+			} else {
+				conns[n] = ic
+				n++
+			}
+		}
+		if n == 0 {
+			delete(p.idle, addr)
+		} else {
+			p.idle[addr] = conns[:n]
+		}
+	}
+}
+
+func (p *ConnPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expire()
+	return p.stats
+}
+
+// Close closes the idle connections. Connections returned afterwards are
+// closed instead of pooled.
+func (p *ConnPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conns := range p.idle {
+		for _, ic := range conns {
+			ic.conn.Close()
+		}
+	}
+	p.idle = make(map[string][]idleConn)
+	p.stats.Idle = 0
+	p.closed = true
+}
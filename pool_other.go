@@ -0,0 +1,7 @@
+//go:build !unix
+
+package kite
+
+func peekIdle(fd uintptr) (idle bool, supported bool) {
+	return false, false
+}
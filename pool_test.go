@@ -0,0 +1,91 @@
+package kite
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vderic/kite-client-go/kitetest"
+)
+
+func keepAlive(s *kitetest.Server) {
+	s.KeepAlive = true
+}
+
+func TestConnPoolDialer(t *testing.T) {
+	srv, schema := startServer(t, keepAlive)
+	pool := NewConnPool()
+	defer pool.Close()
+
+	scanAll(t, newTestClient(srv, schema, 2).ConnPool(pool))
+	reuses := pool.Stats().Reuses
+
+	// the idle direct connections must not be handed to a custom dialer
+	var ndial int32
+	c := newTestClient(srv, schema, 2).ConnPool(pool)
+	c.Dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&ndial, 1)
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	})
+	scanAll(t, c)
+	if ndial != 2 {
+		t.Errorf("dialer called %d times, want 2", ndial)
+	}
+	if got := pool.Stats().Reuses; got != reuses {
+		t.Errorf("%d connections reused by the custom dialer", got-reuses)
+	}
+
+	// but are reused by the client that dialed them
+	scanAll(t, c)
+	if ndial != 2 {
+		t.Errorf("dialer called %d times after reuse, want 2", ndial)
+	}
+}
+
+func TestConnPoolReuse(t *testing.T) {
+	srv, schema := startServer(t, keepAlive)
+	pool := NewConnPool()
+	defer pool.Close()
+
+	ref, _ := startServer(t, nil)
+	want := scanAll(t, newTestClient(ref, schema, 1))
+	for i := 0; i < 3; i++ {
+		n := scanAll(t, newTestClient(srv, schema, 4).ConnPool(pool))
+		if n != 4*want {
+			t.Fatalf("query %d: got %d rows, want %d", i, n, 4*want)
+		}
+		stats := pool.Stats()
+		if stats.Reuses != 4*i {
+			t.Errorf("query %d: %d reuses, want %d", i, stats.Reuses, 4*i)
+		}
+	}
+	if got := srv.Conns(); got != 4 {
+		t.Errorf("server accepted %d connections, want 4", got)
+	}
+	if stats := pool.Stats(); stats.Dials != 4 || stats.Idle != 4 {
+		t.Errorf("got %+v, want 4 dials and 4 idle connections", stats)
+	}
+}
+
+func TestConnPoolEvict(t *testing.T) {
+	// the server closes every connection after BYE_
+	srv, schema := startServer(t, nil)
+	pool := NewConnPool()
+	defer pool.Close()
+
+	want := scanAll(t, newTestClient(srv, schema, 2).ConnPool(pool))
+	// wait for the closes to arrive
+	time.Sleep(50 * time.Millisecond)
+
+	n := scanAll(t, newTestClient(srv, schema, 2).ConnPool(pool))
+	if n != want {
+		t.Errorf("got %d rows, want %d", n, want)
+	}
+	stats := pool.Stats()
+	if stats.Reuses != 0 || stats.Evicted < 2 || stats.Dials != 4 {
+		t.Errorf("got %+v, want the 2 closed connections evicted and dialed again", stats)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package kite
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// peekIdle reports whether the socket has neither data nor EOF pending.
+func peekIdle(fd uintptr) (idle bool, supported bool) {
+	var b [1]byte
+	_, _, err := unix.Recvfrom(int(fd), b[:], unix.MSG_PEEK|unix.MSG_DONTWAIT)
+	// anything but EAGAIN is data, EOF or an error
+	return err == unix.EAGAIN || err == unix.EWOULDBLOCK, true
+}
@@ -89,6 +89,10 @@ func main() {
 		fmt.Print("\n")
 		n++
 	}
+	if it.Err() != nil {
+		fmt.Println(it.Err())
+		return
+	}
 	fmt.Println("#rows = ", n)
 
 }
@@ -0,0 +1,134 @@
+package xrg
+
+import (
+	"fmt"
+)
+
+// ArrayValues returns the array elements as []T together with a validity
+// mask. valid[i] is false for NULL elements, whose value is the zero T.
+func ArrayValues[T any](arr ArrayType) (values []T, valid []bool, err error) {
+	values = make([]T, len(arr.Values))
+	valid = make([]bool, len(arr.Values))
+	for i, v := range arr.Values {
+		if v == nil {
+			continue
+		}
+		t, ok := v.(T)
+		if !ok {
+			return nil, nil, fmt.Errorf("array element is %T, not %T", v, t)
+		}
+		values[i] = t
+		valid[i] = true
+	}
+	return values, valid, nil
+}
+
+// ArrayPointers returns the array elements as []*T with nil for NULL elements.
+func ArrayPointers[T any](arr ArrayType) ([]*T, error) {
+	values, valid, err := ArrayValues[T](arr)
+	if err != nil {
+		return nil, err
+	}
+
+	ptrs := make([]*T, len(values))
+	for i := range values {
+		if valid[i] {
+			ptrs[i] = &values[i]
+		}
+	}
+	return ptrs, nil
+}
+
+func (arr *ArrayType) checkPtyp(ptyp PhysicalType) error {
+	if arr.Header.Ptyp != ptyp {
+		return fmt.Errorf("array physical type is %d, not %d", arr.Header.Ptyp, ptyp)
+	}
+	return nil
+}
+
+func (arr *ArrayType) Int8s() ([]int8, []bool, error) {
+	if err := arr.checkPtyp(XRG_PTYP_INT8); err != nil {
+		return nil, nil, err
+	}
+	bytes, valid, err := ArrayValues[byte](*arr)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]int8, len(bytes))
+	for i, b := range bytes {
+		values[i] = int8(b)
+	}
+	return values, valid, nil
+}
+
+func (arr *ArrayType) Int16s() ([]int16, []bool, error) {
+	if err := arr.checkPtyp(XRG_PTYP_INT16); err != nil {
+		return nil, nil, err
+	}
+	return ArrayValues[int16](*arr)
+}
+
+func (arr *ArrayType) Int32s() ([]int32, []bool, error) {
+	if err := arr.checkPtyp(XRG_PTYP_INT32); err != nil {
+		return nil, nil, err
+	}
+	return ArrayValues[int32](*arr)
+}
+
+func (arr *ArrayType) Int64s() ([]int64, []bool, error) {
+	if err := arr.checkPtyp(XRG_PTYP_INT64); err != nil {
+		return nil, nil, err
+	}
+	return ArrayValues[int64](*arr)
+}
+
+func (arr *ArrayType) Float32s() ([]float32, []bool, error) {
+	if err := arr.checkPtyp(XRG_PTYP_FP32); err != nil {
+		return nil, nil, err
+	}
+	return ArrayValues[float32](*arr)
+}
+
+func (arr *ArrayType) Float64s() ([]float64, []bool, error) {
+	if err := arr.checkPtyp(XRG_PTYP_FP64); err != nil {
+		return nil, nil, err
+	}
+	return ArrayValues[float64](*arr)
+}
+
+func (arr *ArrayType) Strings() ([]string, []bool, error) {
+	if arr.Header.Ltyp != XRG_LTYP_STRING {
+		return nil, nil, fmt.Errorf("array logical type is %d, not string", arr.Header.Ltyp)
+	}
+	return ArrayValues[string](*arr)
+}
+
+func (arr *ArrayType) Intervals() ([]Interval, []bool, error) {
+	if arr.Header.Ltyp != XRG_LTYP_INTERVAL {
+		return nil, nil, fmt.Errorf("array logical type is %d, not interval", arr.Header.Ltyp)
+	}
+	return ArrayValues[Interval](*arr)
+}
+
+// Decimals returns decimal[] elements with the array precision and scale
+// applied, regardless of whether they are stored as int64 or int128.
+func (arr *ArrayType) Decimals() ([]Decimal, []bool, error) {
+	if arr.Header.Ltyp != XRG_LTYP_DECIMAL {
+		return nil, nil, fmt.Errorf("array logical type is %d, not decimal", arr.Header.Ltyp)
+	}
+
+	values := make([]Decimal, len(arr.Values))
+	valid := make([]bool, len(arr.Values))
+	for i, v := range arr.Values {
+		if v == nil {
+			continue
+		}
+		d, ok := NewDecimal(v, arr.Precision, arr.Scale)
+		if !ok {
+			return nil, nil, fmt.Errorf("array element is %T, not decimal", v)
+		}
+		values[i] = d
+		valid[i] = true
+	}
+	return values, valid, nil
+}
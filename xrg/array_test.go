@@ -0,0 +1,197 @@
+package xrg
+
+import (
+	"math/big"
+	"reflect"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+// decodeArray round-trips arr through EncodeArray and NewArrayType.
+func decodeArray(t *testing.T, arr ArrayType) ArrayType {
+	t.Helper()
+	b, err := EncodeArray(arr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewArrayType(uintptr(unsafe.Pointer(&b[0])), arr.Precision, arr.Scale)
+	runtime.KeepAlive(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestArrayRoundTrip(t *testing.T) {
+	arr := decodeArray(t, ArrayType{
+		Header: ArrayHeader{Ptyp: XRG_PTYP_INT32, Ltyp: XRG_LTYP_NONE},
+		Dims:   []int32{2, 3},
+		Lbs:    []int32{0, -1},
+		Values: []any{int32(1), nil, int32(3), int32(4), int32(5), nil},
+	})
+
+	if !reflect.DeepEqual(arr.Dims, []int32{2, 3}) || !reflect.DeepEqual(arr.Lbs, []int32{0, -1}) {
+		t.Errorf("got dims %v lbs %v", arr.Dims, arr.Lbs)
+	}
+	values := []any{int32(1), nil, int32(3), int32(4), int32(5), nil}
+	if !reflect.DeepEqual(arr.Values, values) {
+		t.Errorf("got values %v, want %v", arr.Values, values)
+	}
+	nested := []any{
+		[]any{int32(1), nil, int32(3)},
+		[]any{int32(4), int32(5), nil},
+	}
+	if !reflect.DeepEqual(arr.Nested(), nested) {
+		t.Errorf("got nested %v, want %v", arr.Nested(), nested)
+	}
+
+	at := []struct {
+		subscripts []int32
+		want       any
+	}{
+		{[]int32{0, -1}, int32(1)},
+		{[]int32{0, 0}, nil},
+		{[]int32{1, -1}, int32(4)},
+		{[]int32{1, 1}, nil},
+	}
+	for _, tt := range at {
+		v, err := arr.At(tt.subscripts...)
+		if err != nil || v != tt.want {
+			t.Errorf("At%v: got %v, %v, want %v", tt.subscripts, v, err, tt.want)
+		}
+	}
+
+	for _, subscripts := range [][]int32{{-1, 0}, {2, 0}, {0, -2}, {0, 2}, {0}, {0, 0, 0}} {
+		_, err := arr.Offset(subscripts...)
+		if err == nil {
+			t.Errorf("Offset%v: no error", subscripts)
+		}
+	}
+}
+
+func TestArrayEmpty(t *testing.T) {
+	arr := decodeArray(t, ArrayType{})
+	if len(arr.Dims) != 0 || len(arr.Values) != 0 || len(arr.Nested()) != 0 {
+		t.Errorf("got dims %v values %v", arr.Dims, arr.Values)
+	}
+}
+
+func TestArrayTypedValues(t *testing.T) {
+	arr := func(ptyp PhysicalType, ltyp LogicalType, values ...any) *ArrayType {
+		a := decodeArray(t, ArrayType{
+			Header: ArrayHeader{Ptyp: ptyp, Ltyp: ltyp},
+			Dims:   []int32{int32(len(values))},
+			Lbs:    []int32{1},
+			Values: values,
+		})
+		return &a
+	}
+	check := func(name string, values, want any, valid []bool, err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			return
+		}
+		if !reflect.DeepEqual(values, want) || !reflect.DeepEqual(valid, []bool{true, false, true}) {
+			t.Errorf("%s: got %v %v, want %v [true false true]", name, values, valid, want)
+		}
+	}
+
+	i8, valid, err := arr(XRG_PTYP_INT8, XRG_LTYP_NONE, int8(-1), nil, int8(2)).Int8s()
+	check("Int8s", i8, []int8{-1, 0, 2}, valid, err)
+	i16, valid, err := arr(XRG_PTYP_INT16, XRG_LTYP_NONE, int16(-1), nil, int16(2)).Int16s()
+	check("Int16s", i16, []int16{-1, 0, 2}, valid, err)
+	i32, valid, err := arr(XRG_PTYP_INT32, XRG_LTYP_NONE, int32(-1), nil, int32(2)).Int32s()
+	check("Int32s", i32, []int32{-1, 0, 2}, valid, err)
+	i64, valid, err := arr(XRG_PTYP_INT64, XRG_LTYP_NONE, int64(-1), nil, int64(2)).Int64s()
+	check("Int64s", i64, []int64{-1, 0, 2}, valid, err)
+	f32, valid, err := arr(XRG_PTYP_FP32, XRG_LTYP_NONE, float32(-1.5), nil, float32(2)).Float32s()
+	check("Float32s", f32, []float32{-1.5, 0, 2}, valid, err)
+	f64, valid, err := arr(XRG_PTYP_FP64, XRG_LTYP_NONE, -1.5, nil, 2.0).Float64s()
+	check("Float64s", f64, []float64{-1.5, 0, 2}, valid, err)
+	strs, valid, err := arr(XRG_PTYP_BYTEA, XRG_LTYP_STRING, "a", nil, "bc").Strings()
+	check("Strings", strs, []string{"a", "", "bc"}, valid, err)
+	iv := Interval{Usec: 1, Day: 2, Mon: 3}
+	ivs, valid, err := arr(XRG_PTYP_INT128, XRG_LTYP_INTERVAL, iv, nil, iv).Intervals()
+	check("Intervals", ivs, []Interval{iv, {}, iv}, valid, err)
+
+	dec := decodeArray(t, ArrayType{
+		Header:    ArrayHeader{Ptyp: XRG_PTYP_INT64, Ltyp: XRG_LTYP_DECIMAL},
+		Precision: 10,
+		Scale:     3,
+		Dims:      []int32{3},
+		Lbs:       []int32{1},
+		Values:    []any{int64(-5), nil, int64(12345)},
+	})
+	decs, valid, err := dec.Decimals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := []string{decs[0].String(), decs[2].String()}; !reflect.DeepEqual(got, []string{"-0.005", "12.345"}) {
+		t.Errorf("Decimals: got %v", got)
+	}
+	if !reflect.DeepEqual(valid, []bool{true, false, true}) || decs[1].Unscaled != nil {
+		t.Errorf("Decimals: got validity %v and NULL %v", valid, decs[1])
+	}
+
+	ptrs, err := ArrayPointers[int32](*arr(XRG_PTYP_INT32, XRG_LTYP_NONE, int32(-1), nil, int32(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ptrs) != 3 || *ptrs[0] != -1 || ptrs[1] != nil || *ptrs[2] != 2 {
+		t.Errorf("ArrayPointers: got %v", ptrs)
+	}
+}
+
+func TestArrayTypedMismatch(t *testing.T) {
+	arr := int32Array(1, nil)
+	if _, _, err := arr.Int64s(); err == nil {
+		t.Error("Int64s of int32[]: expected error")
+	}
+	if _, _, err := arr.Int8s(); err == nil {
+		t.Error("Int8s of int32[]: expected error")
+	}
+	if _, _, err := arr.Strings(); err == nil {
+		t.Error("Strings of int32[]: expected error")
+	}
+	if _, _, err := arr.Decimals(); err == nil {
+		t.Error("Decimals of int32[]: expected error")
+	}
+	if _, _, err := ArrayValues[int64](arr); err == nil {
+		t.Error("ArrayValues[int64] of int32[]: expected error")
+	}
+	if _, err := ArrayPointers[string](arr); err == nil {
+		t.Error("ArrayPointers[string] of int32[]: expected error")
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	big128, _ := I128FromBigInt(new(big.Int).Lsh(big.NewInt(-1), 100))
+	tests := []struct {
+		v     any
+		scale int16
+		want  string
+	}{
+		{int64(12345), 2, "123.45"},
+		{int64(-12345), 2, "-123.45"},
+		{int64(5), 3, "0.005"},
+		{int64(-5), 3, "-0.005"},
+		{int64(-123), 3, "-0.123"},
+		{int64(0), 2, "0.00"},
+		{int64(-42), 0, "-42"},
+		{big128, 10, "-126765060022822940149.6703205376"},
+	}
+	for _, tt := range tests {
+		d, ok := NewDecimal(tt.v, 38, tt.scale)
+		if !ok {
+			t.Fatalf("NewDecimal(%v) failed", tt.v)
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("Decimal(%v, scale %d) = %q, want %q", tt.v, tt.scale, got, tt.want)
+		}
+	}
+	if _, ok := NewDecimal(int32(1), 10, 2); ok {
+		t.Error("NewDecimal(int32): expected failure")
+	}
+}
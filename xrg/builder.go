@@ -0,0 +1,304 @@
+package xrg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/pierrec/lz4"
+)
+
+// VectorBuilder encodes the values of one column into a vector. NULL values
+// are stored as zeros (an empty bytea for strings, an empty array for
+// arrays).
+type VectorBuilder struct {
+	Header VectorHeader
+	data   []byte
+	flag   []byte
+}
+
+func NewVectorBuilder(ptyp PhysicalType, ltyp LogicalType, fieldidx int16, precision int16, scale int16) *VectorBuilder {
+	b := new(VectorBuilder)
+	copy(b.Header.Magic[:], XRG_MAGIC)
+	b.Header.Ptyp = ptyp
+	b.Header.Ltyp = ltyp
+	b.Header.Fieldidx = fieldidx
+	b.Header.Itemsz = ptypItemsz(ptyp)
+	b.Header.Precision = precision
+	b.Header.Scale = scale
+	return b
+}
+
+func (b *VectorBuilder) Len() int {
+	return len(b.flag)
+}
+
+func (b *VectorBuilder) AppendNull() {
+	if b.Header.Ltyp == XRG_LTYP_ARRAY {
+		// store an empty array so that readers can always decode it
+		empty, _ := EncodeArray(ArrayType{})
+		b.data = binary.LittleEndian.AppendUint32(b.data, uint32(len(empty)))
+		b.data = append(b.data, empty...)
+	} else {
+		sz := int(b.Header.Itemsz)
+		if sz < 0 {
+			sz = 4
+		}
+		b.data = append(b.data, make([]byte, sz)...)
+	}
+	b.flag = append(b.flag, XRG_FLAG_NULL)
+	b.Header.Nnull++
+}
+
+// Append adds a value of the Go type PointerGetValue returns for the
+// vector type. Variable length vectors also accept []byte.
+func (b *VectorBuilder) Append(v any) error {
+	var err error
+	if b.Header.Ptyp == XRG_PTYP_BYTEA {
+		var payload []byte
+		switch x := v.(type) {
+		case string:
+			payload = []byte(x)
+		case []byte:
+			payload = x
+		case ArrayType:
+			payload, err = EncodeArray(x)
+		default:
+			err = fmt.Errorf("cannot append %T to %v vector", v, b.Header.Ptyp)
+		}
+		if err != nil {
+			return err
+		}
+		b.data = binary.LittleEndian.AppendUint32(b.data, uint32(len(payload)))
+		b.data = append(b.data, payload...)
+	} else {
+		b.data, err = appendFixed(b.data, b.Header.Ptyp, v)
+		if err != nil {
+			return err
+		}
+	}
+	b.flag = append(b.flag, 0)
+	return nil
+}
+
+func appendFixed(data []byte, ptyp PhysicalType, v any) ([]byte, error) {
+	le := binary.LittleEndian
+	switch x := v.(type) {
+	case byte:
+		if ptyp == XRG_PTYP_INT8 {
+			return append(data, x), nil
+		}
+	case int8:
+		if ptyp == XRG_PTYP_INT8 {
+			return append(data, byte(x)), nil
+		}
+	case int16:
+		if ptyp == XRG_PTYP_INT16 {
+			return le.AppendUint16(data, uint16(x)), nil
+		}
+	case int32:
+		if ptyp == XRG_PTYP_INT32 {
+			return le.AppendUint32(data, uint32(x)), nil
+		}
+	case int64:
+		if ptyp == XRG_PTYP_INT64 {
+			return le.AppendUint64(data, uint64(x)), nil
+		}
+	case float32:
+		if ptyp == XRG_PTYP_FP32 {
+			return le.AppendUint32(data, math.Float32bits(x)), nil
+		}
+	case float64:
+		if ptyp == XRG_PTYP_FP64 {
+			return le.AppendUint64(data, math.Float64bits(x)), nil
+		}
+	case I128:
+		if ptyp == XRG_PTYP_INT128 {
+			hi, lo := x.GetHiLo()
+			return le.AppendUint64(le.AppendUint64(data, lo), hi), nil
+		}
+	case Interval:
+		if ptyp == XRG_PTYP_INT128 {
+			data = le.AppendUint64(data, uint64(x.Usec))
+			data = le.AppendUint32(data, uint32(x.Day))
+			return le.AppendUint32(data, uint32(x.Mon)), nil
+		}
+	}
+	return data, fmt.Errorf("cannot append %T to %v vector", v, ptyp)
+}
+
+// EncodeArray serializes the array in the layout NewArrayType reads.
+// Elements are taken from Values, nil being NULL.
+func EncodeArray(arr ArrayType) ([]byte, error) {
+	ndim := int32(len(arr.Dims))
+	if ndim != int32(len(arr.Lbs)) {
+		return nil, fmt.Errorf("array has %d dims but %d lower bounds", ndim, len(arr.Lbs))
+	}
+	nitems := arr.GetNitem(ndim, arr.Dims)
+	if int(nitems) != len(arr.Values) {
+		return nil, fmt.Errorf("array dims hold %d items but %d values given", nitems, len(arr.Values))
+	}
+
+	hasnull := false
+	for _, v := range arr.Values {
+		if v == nil {
+			hasnull = true
+			break
+		}
+	}
+
+	hdrsz := arr.GetOverHeadNoNulls(ndim)
+	dataoffset := int32(0)
+	if hasnull {
+		hdrsz = arr.GetOverHeadWithNulls(ndim, nitems)
+		dataoffset = int32(hdrsz)
+	}
+
+	le := binary.LittleEndian
+	buf := make([]byte, XRG_ARRAY_HEADER_SIZE, hdrsz)
+	le.PutUint32(buf[4:], uint32(ndim))
+	le.PutUint32(buf[8:], uint32(dataoffset))
+	le.PutUint16(buf[12:], uint16(arr.Header.Ptyp))
+	le.PutUint16(buf[14:], uint16(arr.Header.Ltyp))
+	for _, d := range arr.Dims {
+		buf = le.AppendUint32(buf, uint32(d))
+	}
+	for _, lb := range arr.Lbs {
+		buf = le.AppendUint32(buf, uint32(lb))
+	}
+	if hasnull {
+		bitmap := make([]byte, (nitems+7)/8)
+		for i, v := range arr.Values {
+			if v != nil {
+				bitmap[i/8] |= 1 << (i % 8)
+			}
+		}
+		buf = append(buf, bitmap...)
+	}
+	buf = append(buf, make([]byte, int(hdrsz)-len(buf))...)
+
+	var err error
+	for _, v := range arr.Values {
+		if v == nil {
+			continue
+		}
+		if arr.Header.Ptyp != XRG_PTYP_BYTEA {
+			buf, err = appendFixed(buf, arr.Header.Ptyp, v)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var payload []byte
+		switch x := v.(type) {
+		case string:
+			payload = []byte(x)
+		case ArrayType:
+			payload, err = EncodeArray(x)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("cannot encode %T as bytea array element", v)
+		}
+		buf = le.AppendUint32(buf, uint32(len(payload)))
+		buf = append(buf, payload...)
+	}
+
+	le.PutUint32(buf[0:], uint32(len(buf)))
+	return buf, nil
+}
+
+// Bytes returns the vector as stored in XRG files and sent in VEC_
+// messages. The data is lz4 compressed if compress is set and it shrinks.
+func (b *VectorBuilder) Bytes(compress bool) ([]byte, error) {
+	hdr := b.Header
+	hdr.Nbyte = int32(len(b.data))
+	hdr.Zbyte = hdr.Nbyte
+	hdr.Nitem = int32(len(b.flag))
+
+	zdata := b.data
+	if compress && len(b.data) > 0 {
+		dst := make([]byte, lz4.CompressBlockBound(len(b.data)))
+		n, err := lz4.CompressBlock(b.data, dst, nil)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 && n < len(b.data) {
+			zdata = dst[:n]
+			hdr.Zbyte = int32(n)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(XRG_HEADER_SIZE + len(zdata) + len(b.flag))
+	err := binary.Write(&buf, binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, err
+	}
+	// the C header is padded to align Unused2
+	buf.Write(make([]byte, XRG_HEADER_SIZE-buf.Len()))
+	buf.Write(zdata)
+	buf.Write(b.flag)
+	return buf.Bytes(), nil
+}
+
+// Reset clears the values so that the builder can be reused for the next
+// row group.
+func (b *VectorBuilder) Reset() {
+	b.data = b.data[:0]
+	b.flag = b.flag[:0]
+	b.Header.Nnull = 0
+}
+
+// Writer writes vectors into an XRG file. Vectors are aligned to 16 bytes
+// and the offsets and footer are written by Close.
+type Writer struct {
+	w       io.Writer
+	off     int64
+	offsets []int64
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) pad() error {
+	n := int(Align(16, int32(w.off%16))) - int(w.off%16)
+	if n == 0 {
+		return nil
+	}
+	_, err := w.w.Write(make([]byte, n))
+	w.off += int64(n)
+	return err
+}
+
+func (w *Writer) WriteVector(vec []byte) error {
+	err := w.pad()
+	if err != nil {
+		return err
+	}
+	w.offsets = append(w.offsets, w.off)
+	n, err := w.w.Write(vec)
+	w.off += int64(n)
+	return err
+}
+
+func (w *Writer) Close() error {
+	err := w.pad()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, len(w.offsets)*8+XRG_FOOTER_SIZE)
+	for _, off := range w.offsets {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(off))
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(w.offsets)))
+	buf = append(buf, XRG_MAGIC...)
+	_, err = w.w.Write(buf)
+	return err
+}
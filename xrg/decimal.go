@@ -0,0 +1,52 @@
+package xrg
+
+import (
+	"math/big"
+	"strings"
+)
+
+type Decimal struct {
+	Unscaled  *big.Int
+	Precision int16
+	Scale     int16
+}
+
+func NewDecimal(v any, precision int16, scale int16) (Decimal, bool) {
+	d := Decimal{Precision: precision, Scale: scale}
+	switch x := v.(type) {
+	case int64:
+		d.Unscaled = big.NewInt(x)
+	case I128:
+		d.Unscaled = x.AsBigInt()
+	default:
+		return d, false
+	}
+	return d, true
+}
+
+func (d Decimal) Rat() *big.Rat {
+	den := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil)
+	return new(big.Rat).SetFrac(d.Unscaled, den)
+}
+
+func (d Decimal) String() string {
+	s := d.Unscaled.String()
+	if d.Scale <= 0 {
+		return s
+	}
+
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	scale := int(d.Scale)
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package xrg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+const XRG_FOOTER_SIZE = 8
+
+// File is an XRG file, vectors stored back to back followed by the int64
+// offset of every vector and a VectorFooter.
+type File struct {
+	Data    []byte
+	Offsets []int64
+}
+
+func ReadFile(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFile(b)
+}
+
+func NewFile(b []byte) (*File, error) {
+	if len(b) < XRG_FOOTER_SIZE {
+		return nil, corruptf("file size %d smaller than footer", len(b))
+	}
+
+	var footer VectorFooter
+	err := binary.Read(bytes.NewReader(b[len(b)-XRG_FOOTER_SIZE:]), binary.LittleEndian, &footer)
+	if err != nil {
+		return nil, fmt.Errorf("binary.Read failed: %w", err)
+	}
+	if !bytes.Equal(footer.Magic[:], XRG_MAGIC) {
+		return nil, corruptf("bad footer magic %q", footer.Magic[:])
+	}
+
+	nvec := int64(footer.Nvec)
+	offoff := int64(len(b)) - XRG_FOOTER_SIZE - nvec*8
+	if nvec < 0 || offoff < 0 {
+		return nil, corruptf("footer has %d vectors for file size %d", nvec, len(b))
+	}
+
+	f := &File{Data: b, Offsets: make([]int64, nvec)}
+	for i := range f.Offsets {
+		f.Offsets[i] = int64(binary.LittleEndian.Uint64(b[offoff+int64(i)*8:]))
+		if f.Offsets[i] < 0 || f.Offsets[i]+XRG_HEADER_SIZE > offoff {
+			return nil, corruptf("vector %d offset %d out of range", i, f.Offsets[i])
+		}
+	}
+	return f, nil
+}
+
+func (f *File) Nvec() int {
+	return len(f.Offsets)
+}
+
+// Raw returns the bytes of vector i as sent over the wire: the header,
+// the (compressed) data and the flags.
+func (f *File) Raw(i int) ([]byte, error) {
+	b := f.Data[f.Offsets[i]:]
+	var hdr VectorHeader
+	err := hdr.Read(b[0:XRG_HEADER_SIZE])
+	if err != nil {
+		return nil, err
+	}
+
+	sz := int64(XRG_HEADER_SIZE) + int64(hdr.Zbyte) + int64(hdr.Nitem)
+	if hdr.Zbyte < 0 || hdr.Nitem < 0 || sz > int64(len(b)) {
+		return nil, corruptf("vector %d at offset %d overflows the file", i, f.Offsets[i])
+	}
+	return b[:sz], nil
+}
+
+func (f *File) Vector(i int) (Vector, error) {
+	b, err := f.Raw(i)
+	if err != nil {
+		return Vector{}, err
+	}
+	return NewVector(b)
+}
+
+// RowGroups splits the vectors of the file into row groups. A new group
+// starts whenever the field index does not increase.
+func (f *File) RowGroups() ([][]int, error) {
+	var groups [][]int
+	prev := int16(-1)
+	for i := range f.Offsets {
+		b, err := f.Raw(i)
+		if err != nil {
+			return nil, err
+		}
+		var hdr VectorHeader
+		err = hdr.Read(b[0:XRG_HEADER_SIZE])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(groups) == 0 || hdr.Fieldidx <= prev {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], i)
+		prev = hdr.Fieldidx
+	}
+	return groups, nil
+}
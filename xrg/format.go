@@ -0,0 +1,159 @@
+package xrg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var epoch = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	dateLayout      = "2006-01-02"
+	timeLayout      = "15:04:05.999999"
+	timestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+// FormatValue renders a value returned by PointerGetValue as postgres text.
+// Dates are days and times and timestamps are microseconds since the unix
+// epoch. Arrays are rendered in the {..} literal syntax.
+func FormatValue(v any, ltyp LogicalType, precision int16, scale int16) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case byte:
+		return strconv.Itoa(int(int8(x)))
+	case int16:
+		return strconv.Itoa(int(x))
+	case int32:
+		if ltyp == XRG_LTYP_DATE {
+			return FormatDate(x)
+		}
+		return strconv.Itoa(int(x))
+	case int64:
+		switch ltyp {
+		case XRG_LTYP_DECIMAL:
+			d, _ := NewDecimal(x, precision, scale)
+			return d.String()
+		case XRG_LTYP_TIME:
+			return FormatTime(x)
+		case XRG_LTYP_TIMESTAMP:
+			return FormatTimestamp(x)
+		}
+		return strconv.FormatInt(x, 10)
+	case I128:
+		if ltyp == XRG_LTYP_DECIMAL {
+			d, _ := NewDecimal(x, precision, scale)
+			return d.String()
+		}
+		return x.String()
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case string:
+		return x
+	case Interval:
+		return x.String()
+	case ArrayType:
+		return FormatArray(x)
+	}
+	return fmt.Sprint(v)
+}
+
+func FormatDate(days int32) string {
+	return epoch.AddDate(0, 0, int(days)).Format(dateLayout)
+}
+
+func FormatTime(usec int64) string {
+	return epoch.Add(time.Duration(usec) * time.Microsecond).Format(timeLayout)
+}
+
+func FormatTimestamp(usec int64) string {
+	return time.UnixMicro(usec).UTC().Format(timestampLayout)
+}
+
+func plural(n int64, unit string) string {
+	if n == 1 || n == -1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// String renders the interval the way postgres does by default, e.g.
+// "1 year 2 mons 3 days 04:05:06.7".
+func (i Interval) String() string {
+	parts := make([]string, 0, 4)
+	if y := int64(i.Mon / 12); y != 0 {
+		parts = append(parts, plural(y, "year"))
+	}
+	if m := int64(i.Mon % 12); m != 0 {
+		parts = append(parts, plural(m, "mon"))
+	}
+	if i.Day != 0 {
+		parts = append(parts, plural(int64(i.Day), "day"))
+	}
+
+	if i.Usec != 0 || len(parts) == 0 {
+		usec := i.Usec
+		sign := ""
+		if usec < 0 {
+			sign = "-"
+			usec = -usec
+		}
+		sec := usec / 1000000
+		frac := usec % 1000000
+		s := fmt.Sprintf("%s%02d:%02d:%02d", sign, sec/3600, (sec/60)%60, sec%60)
+		if frac != 0 {
+			s += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatArray renders the array in postgres literal syntax, e.g. {1,NULL,3}
+// or {{1,2},{3,4}} for 2-D arrays.
+func FormatArray(arr ArrayType) string {
+	var sb strings.Builder
+	if len(arr.Dims) == 0 {
+		return "{}"
+	}
+	formatNested(&sb, &arr, arr.Nested())
+	return sb.String()
+}
+
+func formatNested(sb *strings.Builder, arr *ArrayType, values []any) {
+	sb.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		if nested, ok := v.([]any); ok {
+			formatNested(sb, arr, nested)
+			continue
+		}
+		if v == nil {
+			sb.WriteString("NULL")
+			continue
+		}
+		s := FormatValue(v, arr.Header.Ltyp, arr.Precision, arr.Scale)
+		if arr.Header.Ltyp == XRG_LTYP_STRING {
+			s = quoteArrayElement(s)
+		}
+		sb.WriteString(s)
+	}
+	sb.WriteByte('}')
+}
+
+// quoteArrayElement double quotes s if postgres would need it to parse the
+// element back.
+func quoteArrayElement(s string) string {
+	if s == "" || strings.EqualFold(s, "NULL") || strings.ContainsAny(s, "{},\"\\") ||
+		strings.TrimSpace(s) != s {
+		r := strings.NewReplacer("\\", "\\\\", "\"", "\\\"")
+		return "\"" + r.Replace(s) + "\""
+	}
+	return s
+}
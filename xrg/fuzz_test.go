@@ -0,0 +1,112 @@
+package xrg
+
+import (
+	"testing"
+)
+
+// rawVectors returns the vectors of the test file as sent by kite.
+func rawVectors(t testing.TB) [][]byte {
+	f, err := ReadFile("../test/data/gpdb0_0.xrg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vecs := make([][]byte, f.Nvec())
+	for i := range vecs {
+		vecs[i], err = f.Raw(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return vecs
+}
+
+// smallPage re-encodes the first nrow rows of the test file into
+// uncompressed vectors, a seed small enough for the fuzzer to mutate.
+func smallPage(t testing.TB, nrow int) [][]byte {
+	var vec []Vector
+	for _, b := range rawVectors(t) {
+		v, err := NewVector(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vec = append(vec, v)
+	}
+
+	builders := make([]*VectorBuilder, len(vec))
+	for i, v := range vec {
+		h := v.Header
+		builders[i] = NewVectorBuilder(h.Ptyp, h.Ltyp, h.Fieldidx, h.Precision, h.Scale)
+	}
+	it := NewIterator(vec)
+	for r := 0; r < nrow && it.Next(); r++ {
+		for i, b := range builders {
+			if it.Flag[i] != 0 {
+				b.AppendNull()
+				continue
+			}
+			err := b.Append(it.Value[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	page := make([][]byte, len(builders))
+	for i, b := range builders {
+		var err error
+		page[i], err = b.Bytes(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return page
+}
+
+func FuzzVectorRead(f *testing.F) {
+	for _, b := range rawVectors(f) {
+		f.Add(b)
+	}
+	for _, b := range smallPage(f, 4) {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := NewVectorSafe(data)
+		if err != nil {
+			return
+		}
+		it, err := NewIteratorSafe([]Vector{v})
+		if err != nil {
+			t.Fatalf("valid vector rejected by NewIteratorSafe: %v", err)
+		}
+		for it.Next() {
+		}
+	})
+}
+
+// FuzzIteratorNext reads back to back vectors from data and iterates over
+// all the rows.
+func FuzzIteratorNext(f *testing.F) {
+	var all []byte
+	for _, b := range smallPage(f, 4) {
+		all = append(all, b...)
+	}
+	f.Add(all)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var vec []Vector
+		for len(data) > 0 {
+			v, err := NewVectorSafe(data)
+			if err != nil {
+				return
+			}
+			vec = append(vec, v)
+			data = data[XRG_HEADER_SIZE+int(v.Header.Zbyte)+int(v.Header.Nitem):]
+		}
+
+		it, err := NewIteratorSafe(vec)
+		if err != nil {
+			return
+		}
+		for it.Next() {
+		}
+	})
+}
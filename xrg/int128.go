@@ -45,3 +45,29 @@ func (i I128) String() string {
 func (i I128) GetHiLo() (hi uint64, lo uint64) {
 	return i.array[HI], i.array[LO]
 }
+
+func NewI128(hi uint64, lo uint64) I128 {
+	return I128{[]uint64{lo, hi}}
+}
+
+// I128FromBigInt converts b to a two's complement int128. ok is false if b
+// does not fit.
+func I128FromBigInt(b *big.Int) (i I128, ok bool) {
+	if b.BitLen() > 127 {
+		return i, false
+	}
+
+	abs := new(big.Int).Abs(b)
+	mask := new(big.Int).SetUint64(^uint64(0))
+	lo := new(big.Int).And(abs, mask).Uint64()
+	hi := new(big.Int).Rsh(abs, 64).Uint64()
+	if b.Sign() < 0 {
+		// negate: invert and add one
+		lo, hi = ^lo, ^hi
+		lo++
+		if lo == 0 {
+			hi++
+		}
+	}
+	return NewI128(hi, lo), true
+}
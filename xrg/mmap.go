@@ -0,0 +1,158 @@
+package xrg
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrClosed is returned when an MmapFile is used after Close.
+var ErrClosed = errors.New("xrg: file already closed")
+
+// MmapFile is an XRG file mapped into memory. Vectors are decoded lazily
+// straight from the mapping: the data of uncompressed vectors and the flags
+// of all vectors point into the mapping without copying.
+//
+// Every call that returns vectors takes a reference on the mapping which
+// must be given back with Release once the vectors are no longer used.
+// Close unmaps the file as soon as all references are released, so vectors
+// obtained before Close stay valid until their Release.
+type MmapFile struct {
+	mu     sync.Mutex
+	f      *File
+	unmap  func() error
+	refs   int
+	closed bool
+}
+
+// OpenFile maps the XRG file at path read-only.
+func OpenFile(path string) (*MmapFile, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	st, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if st.Size() < XRG_FOOTER_SIZE {
+		return nil, corruptf("file size %d smaller than footer", st.Size())
+	}
+
+	b, unmap, err := mmap(fp, st.Size())
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFile(b)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	return &MmapFile{f: f, unmap: unmap}, nil
+}
+
+func (m *MmapFile) Nvec() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0
+	}
+	return m.f.Nvec()
+}
+
+func (m *MmapFile) RowGroups() ([][]int, error) {
+	if err := m.Acquire(); err != nil {
+		return nil, err
+	}
+	defer m.Release()
+	return m.f.RowGroups()
+}
+
+// Acquire takes a reference on the mapping.
+func (m *MmapFile) Acquire() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.refs++
+	return nil
+}
+
+// Release gives back a reference taken by Acquire, Vector or Vectors.
+func (m *MmapFile) Release() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.refs == 0 {
+		panic("xrg: MmapFile released more than acquired")
+	}
+	m.refs--
+	if m.closed && m.refs == 0 {
+		return m.release()
+	}
+	return nil
+}
+
+// Vector decodes vector i and takes a reference on the mapping.
+func (m *MmapFile) Vector(i int) (Vector, error) {
+	if err := m.Acquire(); err != nil {
+		return Vector{}, err
+	}
+	if i < 0 || i >= m.f.Nvec() {
+		m.Release()
+		return Vector{}, corruptf("vector %d out of range [0, %d)", i, m.f.Nvec())
+	}
+	v, err := m.f.Vector(i)
+	if err != nil {
+		m.Release()
+		return Vector{}, err
+	}
+	return v, nil
+}
+
+// Vectors decodes the vectors at the given indexes, e.g. a row group
+// returned by RowGroups, and takes a single reference on the mapping.
+func (m *MmapFile) Vectors(idx []int) ([]Vector, error) {
+	if err := m.Acquire(); err != nil {
+		return nil, err
+	}
+	vec := make([]Vector, len(idx))
+	for n, i := range idx {
+		var err error
+		if i < 0 || i >= m.f.Nvec() {
+			err = corruptf("vector %d out of range [0, %d)", i, m.f.Nvec())
+		} else {
+			vec[n], err = m.f.Vector(i)
+		}
+		if err != nil {
+			m.Release()
+			return nil, err
+		}
+	}
+	return vec, nil
+}
+
+// Close unmaps the file once all references are released. Any further
+// call that takes a reference fails with ErrClosed.
+func (m *MmapFile) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.closed = true
+	if m.refs == 0 {
+		return m.release()
+	}
+	return nil
+}
+
+func (m *MmapFile) release() error {
+	// drop the slices so that stray accesses fail loudly instead of
+	// touching unmapped memory
+	m.f.Data = nil
+	m.f.Offsets = nil
+	return m.unmap()
+}
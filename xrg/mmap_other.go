@@ -0,0 +1,18 @@
+//go:build !unix
+
+package xrg
+
+import (
+	"io"
+	"os"
+)
+
+// mmap falls back to reading the whole file on platforms without mmap.
+func mmap(fp *os.File, size int64) ([]byte, func() error, error) {
+	b := make([]byte, size)
+	_, err := io.ReadFull(fp, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, func() error { return nil }, nil
+}
@@ -0,0 +1,135 @@
+package xrg
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMmapFile(t *testing.T) {
+	m, err := OpenFile("../test/data/gpdb0_0.xrg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmapped := 0
+	unmap := m.unmap
+	m.unmap = func() error {
+		unmapped++
+		return unmap()
+	}
+
+	groups, err := m.RowGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0]) != m.Nvec() {
+		t.Fatalf("got row groups %v for %d vectors", groups, m.Nvec())
+	}
+	vec, err := m.Vectors(groups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, err := m.Vector(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the vectors stay readable after Close until the last Release
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unmapped != 0 {
+		t.Fatal("Close unmapped the file with references outstanding")
+	}
+
+	want := testIterator(t)
+	it := NewIterator(vec)
+	for want.Next() {
+		if !it.Next() {
+			t.Fatalf("mapped row group ends at row %d", want.Row())
+		}
+		if got := rowValues(t, &it); !reflect.DeepEqual(got, rowValues(t, &want)) {
+			t.Fatalf("row %d: got %v, want %v", want.Row(), got, rowValues(t, &want))
+		}
+	}
+	if it.Next() {
+		t.Fatal("mapped row group has extra rows")
+	}
+	if one.Header != vec[0].Header {
+		t.Errorf("Vector(0) header %+v, want %+v", one.Header, vec[0].Header)
+	}
+
+	m.Release()
+	if unmapped != 0 {
+		t.Fatal("unmapped with a reference outstanding")
+	}
+	m.Release()
+	if unmapped != 1 {
+		t.Fatalf("unmapped %d times after the last Release", unmapped)
+	}
+
+	if _, err := m.Vector(0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Vector after Close: got %v", err)
+	}
+	if _, err := m.Vectors(groups[0]); !errors.Is(err, ErrClosed) {
+		t.Errorf("Vectors after Close: got %v", err)
+	}
+	if _, err := m.RowGroups(); !errors.Is(err, ErrClosed) {
+		t.Errorf("RowGroups after Close: got %v", err)
+	}
+	if err := m.Acquire(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Acquire after Close: got %v", err)
+	}
+	if err := m.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close: got %v", err)
+	}
+	if m.Nvec() != 0 {
+		t.Errorf("Nvec after Close: %d", m.Nvec())
+	}
+}
+
+func TestMmapFileClose(t *testing.T) {
+	m, err := OpenFile("../test/data/gpdb0_0.xrg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmapped := 0
+	unmap := m.unmap
+	m.unmap = func() error {
+		unmapped++
+		return unmap()
+	}
+
+	if _, err := m.Vector(m.Nvec()); err == nil {
+		t.Error("Vector out of range: expected error")
+	}
+	if _, err := m.Vectors([]int{0, -1}); err == nil {
+		t.Error("Vectors out of range: expected error")
+	}
+
+	// failed calls give their reference back, so Close unmaps at once
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unmapped != 1 {
+		t.Fatalf("unmapped %d times on Close without references", unmapped)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Release without Acquire did not panic")
+		}
+	}()
+	m.Release()
+}
+
+func TestOpenFileErrors(t *testing.T) {
+	if _, err := OpenFile("../test/data/nosuch.xrg"); err == nil {
+		t.Error("missing file: expected error")
+	}
+	if _, err := OpenFile("../test/data/gpdb0.schema"); err == nil {
+		t.Error("not an xrg file: expected error")
+	}
+}
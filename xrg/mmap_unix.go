@@ -0,0 +1,20 @@
+//go:build unix
+
+package xrg
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func mmap(fp *os.File, size int64) ([]byte, func() error, error) {
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("xrg: file size %d too large to map", size)
+	}
+	b, err := syscall.Mmap(int(fp.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mmap %s: %w", fp.Name(), err)
+	}
+	return b, func() error { return syscall.Munmap(b) }, nil
+}
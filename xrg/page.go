@@ -0,0 +1,65 @@
+package xrg
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Page holds the vectors of one page together with the buffers backing
+// them. Vector data, values decoded in zero-copy mode and the slices
+// returned by Iterator.Bytes alias these buffers and are only valid until
+// the last Release. Values decoded in the default mode are copies and stay
+// valid after the page is released.
+type Page struct {
+	Vec  []Vector
+	refs int32
+	free func()
+}
+
+// NewPage returns a page holding one reference. free, if not nil, is called
+// once the last reference is released.
+func NewPage(vec []Vector, free func()) *Page {
+	return &Page{Vec: vec, refs: 1, free: free}
+}
+
+// Retain takes another reference on the page.
+func (p *Page) Retain() {
+	if atomic.AddInt32(&p.refs, 1) <= 1 {
+		panic("xrg: Retain of released page")
+	}
+}
+
+// Release drops a reference and frees the page buffers with the last one.
+func (p *Page) Release() {
+	n := atomic.AddInt32(&p.refs, -1)
+	if n < 0 {
+		panic("xrg: page released more than retained")
+	}
+	if n == 0 && p.free != nil {
+		p.free()
+	}
+}
+
+func (p *Page) Iterator() Iterator {
+	iter := NewIterator(p.Vec)
+	iter.page = p
+	return iter
+}
+
+// IteratorSafe is Iterator with the checks of NewIteratorSafe.
+func (p *Page) IteratorSafe() (Iterator, error) {
+	iter, err := NewIteratorSafe(p.Vec)
+	if err != nil {
+		return iter, err
+	}
+	iter.page = p
+	return iter, nil
+}
+
+// unsafeString returns a string sharing the memory of b.
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
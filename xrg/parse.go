@@ -0,0 +1,356 @@
+package xrg
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseValue parses postgres text as rendered by FormatValue into the Go
+// value PointerGetValue returns for the type. Arrays are parsed with
+// ParseArray.
+func ParseValue(s string, ptyp PhysicalType, ltyp LogicalType, precision int16, scale int16) (any, error) {
+	switch ltyp {
+	case XRG_LTYP_STRING:
+		return s, nil
+	case XRG_LTYP_DECIMAL:
+		return ParseDecimal(s, ptyp, scale)
+	case XRG_LTYP_DATE:
+		t, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return nil, err
+		}
+		days := t.Unix() / 86400
+		if t.Unix()%86400 < 0 {
+			days--
+		}
+		return int32(days), nil
+	case XRG_LTYP_TIME:
+		t, err := time.Parse(timeLayout, s)
+		if err != nil {
+			return nil, err
+		}
+		return t.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)).Microseconds(), nil
+	case XRG_LTYP_TIMESTAMP:
+		t, err := time.Parse(timestampLayout, s)
+		if err != nil {
+			return nil, err
+		}
+		return t.UnixMicro(), nil
+	case XRG_LTYP_INTERVAL:
+		return ParseInterval(s)
+	}
+
+	switch ptyp {
+	case XRG_PTYP_INT8:
+		i, err := strconv.ParseInt(s, 10, 8)
+		return byte(int8(i)), err
+	case XRG_PTYP_INT16:
+		i, err := strconv.ParseInt(s, 10, 16)
+		return int16(i), err
+	case XRG_PTYP_INT32:
+		i, err := strconv.ParseInt(s, 10, 32)
+		return int32(i), err
+	case XRG_PTYP_INT64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		return i, err
+	case XRG_PTYP_INT128:
+		b, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid int128 %q", s)
+		}
+		i, ok := I128FromBigInt(b)
+		if !ok {
+			return nil, fmt.Errorf("int128 %q out of range", s)
+		}
+		return i, nil
+	case XRG_PTYP_FP32:
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	case XRG_PTYP_FP64:
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err
+	}
+	return nil, fmt.Errorf("cannot parse type (%v, %v)", ptyp, ltyp)
+}
+
+// ParseDecimal returns the unscaled value of s as int64 or I128 depending
+// on ptyp. Extra digits beyond scale are an error.
+func ParseDecimal(s string, ptyp PhysicalType, scale int16) (any, error) {
+	digits := s
+	frac := ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits, frac = s[:i], s[i+1:]
+	}
+	if len(frac) > int(scale) {
+		if strings.TrimRight(frac[scale:], "0") != "" {
+			return nil, fmt.Errorf("decimal %q has more than %d digits after the point", s, scale)
+		}
+		frac = frac[:scale]
+	}
+	digits += frac + strings.Repeat("0", int(scale)-len(frac))
+
+	b, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", s)
+	}
+	if ptyp == XRG_PTYP_INT64 {
+		if !b.IsInt64() {
+			return nil, fmt.Errorf("decimal %q out of range", s)
+		}
+		return b.Int64(), nil
+	}
+	i, ok := I128FromBigInt(b)
+	if !ok {
+		return nil, fmt.Errorf("decimal %q out of range", s)
+	}
+	return i, nil
+}
+
+// ParseInterval parses the postgres interval output format, e.g.
+// "1 year 2 mons 3 days -04:05:06.7".
+func ParseInterval(s string) (Interval, error) {
+	var iv Interval
+	fields := strings.Fields(s)
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.Contains(f, ":") {
+			usec, err := parseClock(f)
+			if err != nil {
+				return iv, fmt.Errorf("invalid interval %q: %w", s, err)
+			}
+			iv.Usec += usec
+			continue
+		}
+
+		if i+1 >= len(fields) {
+			return iv, fmt.Errorf("invalid interval %q", s)
+		}
+		n, err := strconv.ParseInt(f, 10, 32)
+		if err != nil {
+			return iv, fmt.Errorf("invalid interval %q: %w", s, err)
+		}
+		i++
+		switch strings.TrimSuffix(fields[i], "s") {
+		case "year":
+			iv.Mon += int32(n) * 12
+		case "mon":
+			iv.Mon += int32(n)
+		case "day":
+			iv.Day += int32(n)
+		default:
+			return iv, fmt.Errorf("invalid interval unit %q", fields[i])
+		}
+	}
+	return iv, nil
+}
+
+func parseClock(s string) (int64, error) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	secs, frac, _ := strings.Cut(parts[2], ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	usec := int64(0)
+	if frac != "" {
+		if len(frac) > 6 {
+			frac = frac[:6]
+		}
+		usec, err = strconv.ParseInt(frac+strings.Repeat("0", 6-len(frac)), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	usec += ((h*60+m)*60 + sec) * 1000000
+	if neg {
+		usec = -usec
+	}
+	return usec, nil
+}
+
+// ParseArray parses a postgres array literal such as {1,NULL,3} or
+// {{"a b",c},{d,e}} with elements of the given type.
+func ParseArray(s string, ptyp PhysicalType, ltyp LogicalType, precision int16, scale int16) (ArrayType, error) {
+	arr := ArrayType{Precision: precision, Scale: scale}
+	arr.Header.Ptyp = ptyp
+	arr.Header.Ltyp = ltyp
+
+	p := arrayParser{s: strings.TrimSpace(s)}
+	nested, err := p.parse()
+	if err != nil {
+		return arr, fmt.Errorf("invalid array %q: %w", s, err)
+	}
+	if p.pos != len(p.s) {
+		return arr, fmt.Errorf("invalid array %q: trailing characters", s)
+	}
+	if len(nested) == 0 {
+		arr.Values = make([]any, 0)
+		return arr, nil
+	}
+
+	// dimensions follow the first element at every level
+	var level any = nested
+	for {
+		l, ok := level.([]any)
+		if !ok {
+			break
+		}
+		arr.Dims = append(arr.Dims, int32(len(l)))
+		arr.Lbs = append(arr.Lbs, 1)
+		if len(l) == 0 {
+			break
+		}
+		level = l[0]
+	}
+	if len(arr.Dims) > XRG_ARRAY_MAXDIM {
+		return arr, fmt.Errorf("invalid array %q: too many dimensions", s)
+	}
+	arr.Header.Ndim = int32(len(arr.Dims))
+
+	var flatten func(l []any, dim int) error
+	flatten = func(l []any, dim int) error {
+		if len(l) != int(arr.Dims[dim]) {
+			return fmt.Errorf("invalid array %q: dimensions do not match", s)
+		}
+		for _, e := range l {
+			sub, isList := e.([]any)
+			if isList != (dim+1 < len(arr.Dims)) {
+				return fmt.Errorf("invalid array %q: dimensions do not match", s)
+			}
+			if isList {
+				if err := flatten(sub, dim+1); err != nil {
+					return err
+				}
+				continue
+			}
+			if e == nil {
+				arr.Values = append(arr.Values, nil)
+				continue
+			}
+			v, err := ParseValue(e.(string), ptyp, ltyp, precision, scale)
+			if err != nil {
+				return err
+			}
+			arr.Values = append(arr.Values, v)
+		}
+		return nil
+	}
+	err = flatten(nested, 0)
+	return arr, err
+}
+
+// arrayParser splits an array literal into nested []any of string or nil
+// elements.
+type arrayParser struct {
+	s   string
+	pos int
+}
+
+func (p *arrayParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n' || p.s[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+func (p *arrayParser) parse() ([]any, error) {
+	if p.pos >= len(p.s) || p.s[p.pos] != '{' {
+		return nil, fmt.Errorf("expected '{' at %d", p.pos)
+	}
+	p.pos++
+
+	res := make([]any, 0)
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '}' {
+		p.pos++
+		return res, nil
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unterminated array")
+		}
+
+		switch p.s[p.pos] {
+		case '{':
+			sub, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, sub)
+		case '"':
+			e, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, e)
+		default:
+			start := p.pos
+			for p.pos < len(p.s) && p.s[p.pos] != ',' && p.s[p.pos] != '}' {
+				if p.s[p.pos] == '{' || p.s[p.pos] == '"' {
+					return nil, fmt.Errorf("unexpected %q at %d", p.s[p.pos], p.pos)
+				}
+				p.pos++
+			}
+			e := strings.TrimSpace(p.s[start:p.pos])
+			if strings.EqualFold(e, "NULL") {
+				res = append(res, nil)
+			} else {
+				res = append(res, e)
+			}
+		}
+
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		if p.s[p.pos] == '}' {
+			p.pos++
+			return res, nil
+		}
+		if p.s[p.pos] != ',' {
+			return nil, fmt.Errorf("expected ',' at %d", p.pos)
+		}
+		p.pos++
+	}
+}
+
+func (p *arrayParser) quoted() (string, error) {
+	var sb strings.Builder
+	p.pos++
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '\\':
+			if p.pos >= len(p.s) {
+				return "", fmt.Errorf("unterminated quoted element")
+			}
+			sb.WriteByte(p.s[p.pos])
+			p.pos++
+		case '"':
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted element")
+}
@@ -0,0 +1,254 @@
+package xrg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrCorrupt is wrapped by every error returned from the safe decoding
+// functions when the data does not describe a well-formed vector.
+var ErrCorrupt = errors.New("xrg: corrupt data")
+
+func corruptf(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, a...))
+}
+
+func ptypItemsz(ptyp PhysicalType) int16 {
+	switch ptyp {
+	case XRG_PTYP_INT8:
+		return 1
+	case XRG_PTYP_INT16:
+		return 2
+	case XRG_PTYP_INT32, XRG_PTYP_FP32:
+		return 4
+	case XRG_PTYP_INT64, XRG_PTYP_FP64:
+		return 8
+	case XRG_PTYP_INT128:
+		return 16
+	case XRG_PTYP_BYTEA:
+		return -1
+	}
+	return 0
+}
+
+// NewVectorSafe is NewVector followed by Validate.
+func NewVectorSafe(b []byte) (Vector, error) {
+	var v Vector
+	err := v.ReadSafe(b)
+	if err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// ReadSafe reads the vector and validates it so that it can be iterated
+// without reading outside of Data. Use it for data from untrusted sources.
+func (v *Vector) ReadSafe(b []byte) error {
+	return v.ReadSafeAlloc(b, nil)
+}
+
+// ReadSafeAlloc is ReadSafe with the buffer for decompressed data obtained
+// from alloc.
+func (v *Vector) ReadSafeAlloc(b []byte, alloc func(int) []byte) error {
+	if len(b) >= 4 && !bytes.Equal(b[0:4], XRG_MAGIC) {
+		return corruptf("bad vector magic %q", b[0:4])
+	}
+	if len(b) >= XRG_HEADER_SIZE {
+		var hdr VectorHeader
+		err := hdr.Read(b[0:XRG_HEADER_SIZE])
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		// lz4 cannot expand a block by more than 255x
+		if hdr.Nbyte != hdr.Zbyte && int64(hdr.Nbyte) > int64(hdr.Zbyte)*255+16 {
+			return corruptf("Nbyte %d too large for Zbyte %d", hdr.Nbyte, hdr.Zbyte)
+		}
+	}
+	err := v.ReadAlloc(b, alloc)
+	if err != nil {
+		if errors.Is(err, ErrCorrupt) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return v.Validate()
+}
+
+// Validate checks the header against Data and Flag and walks every value,
+// including bytea lengths and array headers.
+func (v *Vector) Validate() error {
+	hdr := &v.Header
+	if hdr.Ptyp <= XRG_PTYP_UNKNOWN || hdr.Ptyp > XRG_PTYP_MAX {
+		return corruptf("invalid physical type %d", hdr.Ptyp)
+	}
+	if hdr.Ltyp <= XRG_LTYP_UNKNOWN || hdr.Ltyp > XRG_LTYP_MAX {
+		return corruptf("invalid logical type %d", hdr.Ltyp)
+	}
+	if hdr.Nitem < 0 || hdr.Nbyte < 0 || hdr.Zbyte < 0 {
+		return corruptf("negative size in header")
+	}
+	if len(v.Flag) != int(hdr.Nitem) {
+		return corruptf("flag size %d != Nitem %d", len(v.Flag), hdr.Nitem)
+	}
+	if v.skipped {
+		return nil
+	}
+	if len(v.Data) != int(hdr.Nbyte) {
+		return corruptf("data size %d != Nbyte %d", len(v.Data), hdr.Nbyte)
+	}
+
+	itemsz := ptypItemsz(hdr.Ptyp)
+	if itemsz > 0 {
+		if hdr.Itemsz != itemsz {
+			return corruptf("itemsz %d does not match physical type %d", hdr.Itemsz, hdr.Ptyp)
+		}
+		if int64(hdr.Nitem)*int64(itemsz) > int64(hdr.Nbyte) {
+			return corruptf("%d items of size %d overflow Nbyte %d", hdr.Nitem, itemsz, hdr.Nbyte)
+		}
+		return nil
+	}
+
+	if hdr.Itemsz > 0 {
+		return corruptf("itemsz %d for variable length type", hdr.Itemsz)
+	}
+
+	off := 0
+	for i := 0; i < int(hdr.Nitem); i++ {
+		b, err := validateByteArray(v.Data, off)
+		if err != nil {
+			return err
+		}
+		if hdr.Ltyp != XRG_LTYP_STRING {
+			err = validateArray(b, off+4)
+			if err != nil {
+				return err
+			}
+		}
+		off += 4 + len(b)
+	}
+	return nil
+}
+
+// validateByteArray returns the bytea payload starting at off.
+func validateByteArray(data []byte, off int) ([]byte, error) {
+	if off+4 > len(data) || off+4 < 0 {
+		return nil, corruptf("bytea length at offset %d beyond data size %d", off, len(data))
+	}
+	sz := int32(binary.LittleEndian.Uint32(data[off:]))
+	if sz < 0 || int64(off)+4+int64(sz) > int64(len(data)) {
+		return nil, corruptf("bytea of length %d at offset %d beyond data size %d", sz, off, len(data))
+	}
+	return data[off+4 : off+4+int(sz)], nil
+}
+
+// validateArray checks an array stored in b. base is the offset of b in
+// the vector data and is only used for error reporting.
+func validateArray(b []byte, base int) error {
+	var hdr ArrayHeader
+	if len(b) == 0 {
+		return nil
+	}
+	if len(b) < XRG_ARRAY_HEADER_SIZE {
+		return corruptf("array at offset %d shorter than header", base)
+	}
+	err := hdr.Read(b[0:XRG_ARRAY_HEADER_SIZE])
+	if err != nil {
+		return corruptf("array at offset %d: %v", base, err)
+	}
+	if int(hdr.Len) != len(b) {
+		return corruptf("array at offset %d: length %d != bytea length %d", base, hdr.Len, len(b))
+	}
+
+	ndim := int(hdr.Ndim)
+	if ndim == 0 {
+		return nil
+	}
+	if ndim < 0 || ndim > XRG_ARRAY_MAXDIM {
+		return corruptf("array at offset %d: ndim %d out of range", base, ndim)
+	}
+	if hdr.Ptyp <= XRG_PTYP_UNKNOWN || hdr.Ptyp > XRG_PTYP_MAX {
+		return corruptf("array at offset %d: invalid element type %d", base, hdr.Ptyp)
+	}
+	if len(b) < XRG_ARRAY_HEADER_SIZE+8*ndim {
+		return corruptf("array at offset %d: truncated dimensions", base)
+	}
+
+	nitems := int64(1)
+	for i := 0; i < ndim; i++ {
+		dim := int32(binary.LittleEndian.Uint32(b[XRG_ARRAY_HEADER_SIZE+4*i:]))
+		if dim < 0 {
+			return corruptf("array at offset %d: negative dimension %d", base, dim)
+		}
+		nitems *= int64(dim)
+		// every item takes at least one bit of the null bitmap
+		if nitems > int64(len(b))*8 || nitems > math.MaxInt32 {
+			return corruptf("array at offset %d: too many items", base)
+		}
+	}
+
+	var arr ArrayType
+	hdrsz := int(arr.GetOverHeadNoNulls(int32(ndim)))
+	if hdr.Dataoffset != 0 {
+		hdrsz = int(arr.GetOverHeadWithNulls(int32(ndim), int32(nitems)))
+		if int(hdr.Dataoffset) != hdrsz {
+			return corruptf("array at offset %d: data offset %d != %d", base, hdr.Dataoffset, hdrsz)
+		}
+	}
+	if hdrsz > len(b) {
+		return corruptf("array at offset %d: header size %d beyond length %d", base, hdrsz, len(b))
+	}
+	var bitmap []byte
+	if hdr.Dataoffset != 0 {
+		bitmapoff := XRG_ARRAY_HEADER_SIZE + 8*ndim
+		bitmap = b[bitmapoff : bitmapoff+int((nitems+7)/8)]
+	}
+
+	itemsz := int(ptypItemsz(hdr.Ptyp))
+	off := hdrsz
+	for i := 0; i < int(nitems); i++ {
+		if bitmap != nil && bitmap[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		if itemsz > 0 {
+			off += itemsz
+			if off > len(b) {
+				return corruptf("array at offset %d: items beyond length %d", base, len(b))
+			}
+			continue
+		}
+
+		elem, err := validateByteArray(b, off)
+		if err != nil {
+			return err
+		}
+		if hdr.Ltyp != XRG_LTYP_STRING {
+			err = validateArray(elem, base+off+4)
+			if err != nil {
+				return err
+			}
+		}
+		off += 4 + len(elem)
+	}
+	return nil
+}
+
+// NewIteratorSafe validates the vectors and checks they are consistent with
+// each other before creating the iterator.
+func NewIteratorSafe(vec []Vector) (Iterator, error) {
+	if len(vec) == 0 {
+		return Iterator{}, corruptf("page has no vectors")
+	}
+	for i := range vec {
+		err := vec[i].Validate()
+		if err != nil {
+			return Iterator{}, err
+		}
+		if vec[i].Header.Nitem != vec[0].Header.Nitem {
+			return Iterator{}, corruptf("vector %d has %d items, vector 0 has %d", i, vec[i].Header.Nitem, vec[0].Header.Nitem)
+		}
+	}
+	return NewIterator(vec), nil
+}
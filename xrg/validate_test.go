@@ -0,0 +1,173 @@
+package xrg
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// vectorBytes encodes values into an uncompressed vector.
+func vectorBytes(t *testing.T, ptyp PhysicalType, ltyp LogicalType, values ...any) []byte {
+	t.Helper()
+	b := NewVectorBuilder(ptyp, ltyp, 0, 0, 0)
+	for _, v := range values {
+		err := b.Append(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, err := b.Bytes(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func int32Array(values ...any) ArrayType {
+	return ArrayType{
+		Header: ArrayHeader{Ptyp: XRG_PTYP_INT32, Ltyp: XRG_LTYP_NONE},
+		Dims:   []int32{int32(len(values))},
+		Lbs:    []int32{1},
+		Values: values,
+	}
+}
+
+func putInt32(b []byte, off int, v int32) {
+	binary.LittleEndian.PutUint32(b[off:], uint32(v))
+}
+
+func TestReadSafeCorrupt(t *testing.T) {
+	// offsets in a raw vector
+	const (
+		nitemOff = 28
+		dataOff  = XRG_HEADER_SIZE
+		// the array in the first bytea and its ndim
+		arrayOff = dataOff + 4
+		ndimOff  = arrayOff + 4
+	)
+
+	tests := []struct {
+		name    string
+		raw     func(t *testing.T) []byte
+		corrupt func(b []byte)
+	}{
+		{
+			"bad magic",
+			func(t *testing.T) []byte { return vectorBytes(t, XRG_PTYP_INT32, XRG_LTYP_NONE, int32(1)) },
+			func(b []byte) { b[0] = 'Y' },
+		},
+		{
+			"truncated bytea",
+			func(t *testing.T) []byte { return vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_STRING, "abc", "de") },
+			func(b []byte) { putInt32(b, dataOff, 1000) },
+		},
+		{
+			"negative bytea length",
+			func(t *testing.T) []byte { return vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_STRING, "abc", "de") },
+			func(b []byte) { putInt32(b, dataOff, -1) },
+		},
+		{
+			"ndim too large",
+			func(t *testing.T) []byte {
+				return vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_ARRAY, int32Array(int32(1), int32(2)))
+			},
+			func(b []byte) { putInt32(b, ndimOff, XRG_ARRAY_MAXDIM+1) },
+		},
+		{
+			"negative ndim",
+			func(t *testing.T) []byte {
+				return vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_ARRAY, int32Array(int32(1), int32(2)))
+			},
+			func(b []byte) { putInt32(b, ndimOff, -1) },
+		},
+		{
+			"ndim beyond array",
+			func(t *testing.T) []byte {
+				return vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_ARRAY, int32Array(int32(1), int32(2)))
+			},
+			func(b []byte) { putInt32(b, ndimOff, XRG_ARRAY_MAXDIM) },
+		},
+		{
+			"bitmap beyond array",
+			func(t *testing.T) []byte {
+				return vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_ARRAY, int32Array(int32(1), nil))
+			},
+			func(b []byte) {
+				// 100 items need a 13 byte bitmap, the array is 36 bytes
+				putInt32(b, arrayOff+8, 40)
+				putInt32(b, arrayOff+16, 100)
+			},
+		},
+		{
+			"Nitem beyond flags",
+			func(t *testing.T) []byte { return vectorBytes(t, XRG_PTYP_INT32, XRG_LTYP_NONE, int32(1), int32(2)) },
+			func(b []byte) { putInt32(b, nitemOff, 3) },
+		},
+		{
+			"Nitem beyond data",
+			func(t *testing.T) []byte {
+				return append(vectorBytes(t, XRG_PTYP_INT32, XRG_LTYP_NONE, int32(1), int32(2)), 0, 0)
+			},
+			func(b []byte) { putInt32(b, nitemOff, 4) },
+		},
+	}
+
+	for _, tt := range tests {
+		raw := tt.raw(t)
+		var v Vector
+		err := v.ReadSafe(raw)
+		if err != nil {
+			t.Fatalf("%s: valid vector rejected: %v", tt.name, err)
+		}
+
+		tt.corrupt(raw)
+		err = v.ReadSafe(raw)
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: got %v, want ErrCorrupt", tt.name, err)
+		}
+	}
+}
+
+func TestNewIteratorSafeNitem(t *testing.T) {
+	a, err := NewVectorSafe(vectorBytes(t, XRG_PTYP_INT32, XRG_LTYP_NONE, int32(1), int32(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewVectorSafe(vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_STRING, "abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewIteratorSafe([]Vector{a, b})
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("got %v, want ErrCorrupt", err)
+	}
+	_, err = NewIteratorSafe(nil)
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("empty page: got %v, want ErrCorrupt", err)
+	}
+
+	// a flag slice not matching Nitem
+	a.Flag = a.Flag[:1]
+	_, err = NewIteratorSafe([]Vector{a})
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("short flags: got %v, want ErrCorrupt", err)
+	}
+}
+
+func TestValidateArrayBitmap(t *testing.T) {
+	// ndim=1, dims=[100] and Dataoffset=40 in a 24 byte array whose slice
+	// has no spare capacity to read the bitmap from
+	b := make([]byte, 24)
+	putInt32(b, 0, 24)
+	putInt32(b, 4, 1)
+	putInt32(b, 8, 40)
+	binary.LittleEndian.PutUint16(b[12:], uint16(XRG_PTYP_INT32))
+	putInt32(b, 16, 100)
+	putInt32(b, 20, 1)
+
+	err := validateArray(b[:24:24], 0)
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("got %v, want ErrCorrupt", err)
+	}
+}
@@ -0,0 +1,206 @@
+package xrg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pierrec/lz4"
+)
+
+// Problem is an integrity problem found by Verify. Vector is -1 for
+// problems with the file layout rather than a single vector.
+type Problem struct {
+	Vector int
+	Offset int64
+	Msg    string
+}
+
+func (p Problem) Error() string {
+	if p.Vector < 0 {
+		return fmt.Sprintf("offset %d: %s", p.Offset, p.Msg)
+	}
+	return fmt.Sprintf("vector %d at offset %d: %s", p.Vector, p.Offset, p.Msg)
+}
+
+type verifier struct {
+	problems []Problem
+}
+
+func (v *verifier) report(vec int, offset int64, format string, a ...any) {
+	v.problems = append(v.problems, Problem{vec, offset, fmt.Sprintf(format, a...)})
+}
+
+func (v *verifier) reportErr(vec int, offset int64, err error) {
+	msg := strings.Replace(err.Error(), ErrCorrupt.Error()+": ", "", 1)
+	v.problems = append(v.problems, Problem{vec, offset, msg})
+}
+
+// Verify walks the footer and every vector of an XRG file and returns all
+// the problems found. A nil result means the file is intact.
+func Verify(b []byte) []Problem {
+	var v verifier
+	if len(b) < XRG_FOOTER_SIZE {
+		v.report(-1, 0, "file size %d smaller than footer", len(b))
+		return v.problems
+	}
+
+	footoff := int64(len(b) - XRG_FOOTER_SIZE)
+	if !bytes.Equal(b[footoff+4:], XRG_MAGIC) {
+		v.report(-1, footoff+4, "bad footer magic %q", b[footoff+4:])
+		return v.problems
+	}
+
+	nvec := int64(int32(binary.LittleEndian.Uint32(b[footoff:])))
+	offoff := footoff - nvec*8
+	if nvec < 0 || offoff < 0 {
+		v.report(-1, footoff, "footer has %d vectors for file size %d", nvec, len(b))
+		return v.problems
+	}
+
+	type extent struct {
+		vec        int
+		start, end int64
+	}
+	var extents []extent
+	headers := make([]*VectorHeader, nvec)
+
+	for i := 0; i < int(nvec); i++ {
+		off := int64(binary.LittleEndian.Uint64(b[offoff+int64(i)*8:]))
+		if off < 0 || off+XRG_HEADER_SIZE > offoff {
+			v.report(i, off, "offset out of range [0:%d]", offoff)
+			continue
+		}
+
+		hdr, end := v.vector(i, off, b[off:offoff])
+		if hdr != nil {
+			headers[i] = hdr
+			extents = append(extents, extent{i, off, end})
+		}
+	}
+
+	sort.Slice(extents, func(i, j int) bool { return extents[i].start < extents[j].start })
+	for i := 1; i < len(extents); i++ {
+		if extents[i].start < extents[i-1].end {
+			v.report(extents[i].vec, extents[i].start, "overlaps vector %d ending at offset %d", extents[i-1].vec, extents[i-1].end)
+		}
+	}
+
+	v.rowGroups(headers, b, offoff)
+	return v.problems
+}
+
+// vector checks a single vector and returns its header and end offset, or
+// a nil header if the vector cannot be located.
+func (v *verifier) vector(i int, off int64, b []byte) (*VectorHeader, int64) {
+	hdr := new(VectorHeader)
+	err := hdr.Read(b[0:XRG_HEADER_SIZE])
+	if err != nil {
+		v.reportErr(i, off, err)
+		return nil, 0
+	}
+
+	if !bytes.Equal(hdr.Magic[:], XRG_MAGIC) {
+		v.report(i, off, "bad vector magic %q", hdr.Magic[:])
+	}
+	if hdr.Ptyp <= XRG_PTYP_UNKNOWN || hdr.Ptyp > XRG_PTYP_MAX {
+		v.report(i, off, "invalid physical type %d", hdr.Ptyp)
+		return hdr, off + XRG_HEADER_SIZE
+	}
+	if hdr.Ltyp <= XRG_LTYP_UNKNOWN || hdr.Ltyp > XRG_LTYP_MAX {
+		v.report(i, off, "invalid logical type %d", hdr.Ltyp)
+	}
+	if hdr.Nbyte < 0 || hdr.Zbyte < 0 || hdr.Nitem < 0 || hdr.Nnull < 0 {
+		v.report(i, off, "negative size in header")
+		return hdr, off + XRG_HEADER_SIZE
+	}
+
+	sz := int64(XRG_HEADER_SIZE) + int64(hdr.Zbyte) + int64(hdr.Nitem)
+	if sz > int64(len(b)) {
+		v.report(i, off, "vector size %d overflows the vector area by %d bytes", sz, sz-int64(len(b)))
+		return hdr, off + int64(len(b))
+	}
+
+	zdata := b[XRG_HEADER_SIZE : XRG_HEADER_SIZE+hdr.Zbyte]
+	flag := b[XRG_HEADER_SIZE+hdr.Zbyte : sz]
+
+	nnull := 0
+	for _, f := range flag {
+		if f&XRG_FLAG_NULL != 0 {
+			nnull++
+		}
+	}
+	if nnull != int(hdr.Nnull) {
+		v.report(i, off, "Nnull %d but %d NULL flags", hdr.Nnull, nnull)
+	}
+
+	data := zdata
+	if hdr.Zbyte != hdr.Nbyte {
+		// lz4 cannot expand a block by more than 255x
+		if int64(hdr.Nbyte) > int64(hdr.Zbyte)*255+16 {
+			v.report(i, off, "Nbyte %d too large for Zbyte %d", hdr.Nbyte, hdr.Zbyte)
+			return hdr, off + sz
+		}
+		data = make([]byte, hdr.Nbyte)
+		n, err := lz4.UncompressBlock(zdata, data)
+		if err != nil {
+			v.report(i, off+XRG_HEADER_SIZE, "%v", err)
+			return hdr, off + sz
+		}
+		if n != int(hdr.Nbyte) {
+			v.report(i, off+XRG_HEADER_SIZE, "lz4 block decompressed to %d bytes, Nbyte %d", n, hdr.Nbyte)
+			return hdr, off + sz
+		}
+	}
+
+	itemsz := ptypItemsz(hdr.Ptyp)
+	if itemsz > 0 {
+		if hdr.Itemsz != itemsz {
+			v.report(i, off, "itemsz %d does not match physical type %v", hdr.Itemsz, hdr.Ptyp)
+		} else if int64(hdr.Nitem)*int64(itemsz) != int64(hdr.Nbyte) {
+			v.report(i, off, "%d items of size %d do not fill Nbyte %d", hdr.Nitem, itemsz, hdr.Nbyte)
+		}
+		return hdr, off + sz
+	}
+
+	// data offsets are reported relative to the uncompressed data
+	pos := 0
+	for item := 0; item < int(hdr.Nitem); item++ {
+		elem, err := validateByteArray(data, pos)
+		if err != nil {
+			v.reportErr(i, off+XRG_HEADER_SIZE, fmt.Errorf("item %d: %w", item, err))
+			return hdr, off + sz
+		}
+		if hdr.Ltyp != XRG_LTYP_STRING {
+			if err = validateArray(elem, pos+4); err != nil {
+				v.reportErr(i, off+XRG_HEADER_SIZE, fmt.Errorf("item %d: %w", item, err))
+			}
+		}
+		pos += 4 + len(elem)
+	}
+	if pos != len(data) {
+		v.report(i, off+XRG_HEADER_SIZE, "variable length data ends at %d, Nbyte %d", pos, hdr.Nbyte)
+	}
+	return hdr, off + sz
+}
+
+// rowGroups checks that the vectors of each row group share Nitem. Groups
+// are split the same way as File.RowGroups.
+func (v *verifier) rowGroups(headers []*VectorHeader, b []byte, offoff int64) {
+	first := -1
+	prev := int16(-1)
+	for i, hdr := range headers {
+		if hdr == nil {
+			continue
+		}
+		if first < 0 || hdr.Fieldidx <= prev {
+			first = i
+		} else if hdr.Nitem != headers[first].Nitem {
+			off := int64(binary.LittleEndian.Uint64(b[offoff+int64(i)*8:]))
+			v.report(i, off, "Nitem %d differs from %d of vector %d in the same row group", hdr.Nitem, headers[first].Nitem, first)
+		}
+		prev = hdr.Fieldidx
+	}
+}
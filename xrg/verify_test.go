@@ -0,0 +1,168 @@
+package xrg
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// writeFile lays out vectors as an XRG file.
+func writeFile(t *testing.T, vecs ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, vec := range vecs {
+		err := w.WriteVector(vec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// compressedVector returns an lz4 compressed int32 vector of n rows.
+func compressedVector(t *testing.T, n int) []byte {
+	t.Helper()
+	b := NewVectorBuilder(XRG_PTYP_INT32, XRG_LTYP_NONE, 0, 0, 0)
+	for i := 0; i < n; i++ {
+		err := b.Append(int32(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, err := b.Bytes(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hdr VectorHeader
+	err = hdr.Read(raw[:XRG_HEADER_SIZE])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Zbyte == hdr.Nbyte {
+		t.Fatal("vector not compressed")
+	}
+	return raw
+}
+
+func TestVerifyIntact(t *testing.T) {
+	b, err := os.ReadFile("../test/data/gpdb0_0.xrg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := Verify(b); problems != nil {
+		t.Errorf("test file: %v", problems)
+	}
+
+	str := vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_STRING, "abc", "de")
+	if problems := Verify(writeFile(t, compressedVector(t, 100), str)); problems != nil {
+		t.Errorf("written file: %v", problems)
+	}
+}
+
+func TestVerifyProblems(t *testing.T) {
+	const (
+		nbyteOff = 16
+		zbyteOff = 20
+		nnullOff = 24
+	)
+	tests := []struct {
+		name   string
+		file   func(t *testing.T) []byte
+		vector int
+		msg    string
+	}{
+		{
+			"footer magic",
+			func(t *testing.T) []byte {
+				b := writeFile(t, compressedVector(t, 100))
+				b[len(b)-1] = 'Z'
+				return b
+			},
+			-1, "bad footer magic",
+		},
+		{
+			"vector magic",
+			func(t *testing.T) []byte {
+				vec := vectorBytes(t, XRG_PTYP_INT32, XRG_LTYP_NONE, int32(1))
+				vec[0] = 'Z'
+				return writeFile(t, vec)
+			},
+			0, "bad vector magic",
+		},
+		{
+			"Nitem mismatch",
+			func(t *testing.T) []byte {
+				b := NewVectorBuilder(XRG_PTYP_INT32, XRG_LTYP_NONE, 1, 0, 0)
+				b.Append(int32(1))
+				vec, err := b.Bytes(false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return writeFile(t, vectorBytes(t, XRG_PTYP_INT32, XRG_LTYP_NONE, int32(1), int32(2)), vec)
+			},
+			1, "Nitem 1 differs from 2 of vector 0",
+		},
+		{
+			"Nnull mismatch",
+			func(t *testing.T) []byte {
+				vec := vectorBytes(t, XRG_PTYP_INT32, XRG_LTYP_NONE, int32(1), int32(2))
+				putInt32(vec, nnullOff, 1)
+				return writeFile(t, vec)
+			},
+			0, "Nnull 1 but 0 NULL flags",
+		},
+		{
+			"short lz4 block",
+			func(t *testing.T) []byte {
+				vec := compressedVector(t, 100)
+				putInt32(vec, nbyteOff, 404)
+				return writeFile(t, vec)
+			},
+			0, "lz4 block decompressed to 400 bytes, Nbyte 404",
+		},
+		{
+			"Nbyte beyond lz4 expansion",
+			func(t *testing.T) []byte {
+				vec := compressedVector(t, 100)
+				var hdr VectorHeader
+				hdr.Read(vec[:XRG_HEADER_SIZE])
+				putInt32(vec, nbyteOff, hdr.Zbyte*255+17)
+				return writeFile(t, vec)
+			},
+			0, "too large for Zbyte",
+		},
+		{
+			"variable length data underfill",
+			func(t *testing.T) []byte {
+				vec := vectorBytes(t, XRG_PTYP_BYTEA, XRG_LTYP_STRING, "abc", "de")
+				// 3 bytes after the last item
+				var hdr VectorHeader
+				hdr.Read(vec[:XRG_HEADER_SIZE])
+				end := XRG_HEADER_SIZE + int(hdr.Nbyte)
+				vec = append(vec[:end:end], append([]byte{0, 0, 0}, vec[end:]...)...)
+				putInt32(vec, nbyteOff, hdr.Nbyte+3)
+				putInt32(vec, zbyteOff, hdr.Zbyte+3)
+				return writeFile(t, vec)
+			},
+			0, "variable length data ends at 13, Nbyte 16",
+		},
+	}
+	for _, tt := range tests {
+		problems := Verify(tt.file(t))
+		found := false
+		for _, p := range problems {
+			if p.Vector == tt.vector && strings.Contains(p.Msg, tt.msg) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: got %v, want vector %d %q", tt.name, problems, tt.vector, tt.msg)
+		}
+	}
+}
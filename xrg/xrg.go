@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/pierrec/lz4"
+	"strings"
 	"unsafe"
 )
 
@@ -14,6 +15,8 @@ var XRG_MAGIC = []byte("XRG1")
 
 const XRG_ARRAY_HEADER_SIZE = 16
 
+const XRG_ARRAY_MAXDIM = 6 /* same as postgres MAXDIM */
+
 func Align(alignment int32, value int32) uintptr {
 	return uintptr((value + alignment - 1) & ^(alignment - 1))
 }
@@ -56,6 +59,24 @@ const (
 	XRG_LTYP_MAX       LogicalType = 8
 )
 
+var ptypNames = []string{"unknown", "int8", "int16", "int32", "int64", "int128", "fp32", "fp64", "bytea"}
+
+func (t PhysicalType) String() string {
+	if t < 0 || t > XRG_PTYP_MAX {
+		return fmt.Sprintf("PhysicalType(%d)", int16(t))
+	}
+	return ptypNames[t]
+}
+
+var ltypNames = []string{"unknown", "none", "string", "decimal", "interval", "time", "date", "timestamp", "array"}
+
+func (t LogicalType) String() string {
+	if t < 0 || t > XRG_LTYP_MAX {
+		return fmt.Sprintf("LogicalType(%d)", int16(t))
+	}
+	return ltypNames[t]
+}
+
 var XRG_TYPES = []string{"int8", "int16", "int32", "int64", "float", "double", "decimal",
 	"string", "interval", "time", "date", "timestamp",
 	"int8[]", "int16[]", "int32[]", "int64[]", "float[]", "double[]", "decimal[]",
@@ -70,6 +91,48 @@ func ValidateType(typ string) bool {
 	return false
 }
 
+// ColumnType returns the physical and logical type the server uses for a
+// schema type such as "int64", "decimal" or "string[]".
+func ColumnType(typ string, precision int) (PhysicalType, LogicalType, error) {
+	if strings.HasSuffix(typ, "[]") {
+		if !ValidateType(typ) {
+			return XRG_PTYP_UNKNOWN, XRG_LTYP_UNKNOWN, fmt.Errorf("invalid type %s", typ)
+		}
+		return XRG_PTYP_BYTEA, XRG_LTYP_ARRAY, nil
+	}
+
+	switch typ {
+	case "int8":
+		return XRG_PTYP_INT8, XRG_LTYP_NONE, nil
+	case "int16":
+		return XRG_PTYP_INT16, XRG_LTYP_NONE, nil
+	case "int32":
+		return XRG_PTYP_INT32, XRG_LTYP_NONE, nil
+	case "int64":
+		return XRG_PTYP_INT64, XRG_LTYP_NONE, nil
+	case "float":
+		return XRG_PTYP_FP32, XRG_LTYP_NONE, nil
+	case "double":
+		return XRG_PTYP_FP64, XRG_LTYP_NONE, nil
+	case "decimal":
+		if precision <= 18 {
+			return XRG_PTYP_INT64, XRG_LTYP_DECIMAL, nil
+		}
+		return XRG_PTYP_INT128, XRG_LTYP_DECIMAL, nil
+	case "string":
+		return XRG_PTYP_BYTEA, XRG_LTYP_STRING, nil
+	case "interval":
+		return XRG_PTYP_INT128, XRG_LTYP_INTERVAL, nil
+	case "time":
+		return XRG_PTYP_INT64, XRG_LTYP_TIME, nil
+	case "date":
+		return XRG_PTYP_INT32, XRG_LTYP_DATE, nil
+	case "timestamp":
+		return XRG_PTYP_INT64, XRG_LTYP_TIMESTAMP, nil
+	}
+	return XRG_PTYP_UNKNOWN, XRG_LTYP_UNKNOWN, fmt.Errorf("invalid type %s", typ)
+}
+
 func XRG_LTYP_PTYP(ltyp LogicalType, ptyp PhysicalType) int32 {
 	return (int32(ltyp) << 16) | int32(ptyp)
 }
@@ -108,15 +171,14 @@ func (hdr *VectorHeader) Read(b []byte) error {
 	buf := bytes.NewReader(b)
 	err := binary.Read(buf, binary.LittleEndian, hdr)
 	if err != nil {
-		fmt.Println("binary.Read failed:", err)
-		return err
+		return fmt.Errorf("binary.Read failed: %w", err)
 	}
 	return err
 }
 
 type ArrayHeader struct {
 	Len        int32        /* length of the buffer */
-	Ndim       int32        /* # of dimensions same as postgres. value is always 1 */
+	Ndim       int32        /* # of dimensions same as postgres */
 	Dataoffset int32        /* offset of data, or 0 if no bitmap */
 	Ptyp       PhysicalType /* physical type of the array data */
 	Ltyp       LogicalType  /* logical type of the array data */
@@ -126,19 +188,14 @@ func (hdr *ArrayHeader) Read(b []byte) error {
 	buf := bytes.NewReader(b)
 	err := binary.Read(buf, binary.LittleEndian, hdr)
 	if err != nil {
-		fmt.Println("binary.Read failed:", err)
-		return err
+		return fmt.Errorf("binary.Read failed: %w", err)
 	}
 	return err
 }
 
-func (hdr *ArrayHeader) Print() {
-	fmt.Println("Array Len:", hdr.Len)
-	fmt.Println("Array Ndim:", hdr.Ndim)
-	fmt.Println("Array Offset:", hdr.Dataoffset)
-	fmt.Println("Array Ptyp:", hdr.Ptyp)
-	fmt.Println("Array Ltyp:", hdr.Ltyp)
-
+func (hdr *ArrayHeader) String() string {
+	return fmt.Sprintf("Array Len: %d, Ndim: %d, Offset: %d, Ptyp: %d, Ltyp: %d",
+		hdr.Len, hdr.Ndim, hdr.Dataoffset, hdr.Ptyp, hdr.Ltyp)
 }
 
 type ArrayType struct {
@@ -151,9 +208,15 @@ type ArrayType struct {
 	Values    []any
 }
 
+// NewArrayType decodes the array at dataptr. Dims, Lbs, Bitmap and the
+// element values are copied and do not alias the page.
 func NewArrayType(dataptr uintptr, precision int16, scale int16) (ArrayType, error) {
+	return newArrayType(dataptr, precision, scale, false)
+}
+
+func newArrayType(dataptr uintptr, precision int16, scale int16, nocopy bool) (ArrayType, error) {
 	var a ArrayType
-	err := a.Read(dataptr, precision, scale)
+	err := a.read(dataptr, precision, scale, nocopy)
 	if err != nil {
 		return a, err
 	}
@@ -161,6 +224,10 @@ func NewArrayType(dataptr uintptr, precision int16, scale int16) (ArrayType, err
 }
 
 func (arr *ArrayType) Read(dataptr uintptr, precision int16, scale int16) error {
+	return arr.read(dataptr, precision, scale, false)
+}
+
+func (arr *ArrayType) read(dataptr uintptr, precision int16, scale int16, nocopy bool) error {
 
 	arr.Precision = precision
 	arr.Scale = scale
@@ -182,8 +249,8 @@ func (arr *ArrayType) Read(dataptr uintptr, precision int16, scale int16) error
 		return nil
 	}
 
-	if ndim != 1 {
-		err := fmt.Errorf("array is not 1-D")
+	if ndim < 0 || ndim > XRG_ARRAY_MAXDIM {
+		err := fmt.Errorf("array ndim %d out of range", ndim)
 		return err
 	}
 
@@ -208,8 +275,16 @@ func (arr *ArrayType) Read(dataptr uintptr, precision int16, scale int16) error
 		hdrsz = arr.GetOverHeadWithNulls(ndim, nitems)
 	}
 
+	if !nocopy {
+		arr.Dims = append([]int32(nil), arr.Dims...)
+		arr.Lbs = append([]int32(nil), arr.Lbs...)
+		if arr.Bitmap != nil {
+			arr.Bitmap = append([]byte(nil), arr.Bitmap...)
+		}
+	}
+
 	ptr = dataptr + hdrsz
-	arr.Values, err = arr.PointerGetArray(ptr, nitems)
+	arr.Values, err = arr.pointerGetArray(ptr, nitems, nocopy)
 	if err != nil {
 		return err
 	}
@@ -221,7 +296,64 @@ func (arr *ArrayType) GetNitem(ndim int32, dims []int32) int32 {
 	if ndim == 0 {
 		return 0
 	}
-	return dims[0]
+	nitems := int32(1)
+	for i := int32(0); i < ndim; i++ {
+		nitems *= dims[i]
+	}
+	return nitems
+}
+
+// Offset converts postgres style subscripts (relative to Lbs) into the
+// position of the element in Values. Values are stored in row-major order.
+func (arr *ArrayType) Offset(subscripts ...int32) (int32, error) {
+	ndim := len(arr.Dims)
+	if len(subscripts) != ndim {
+		return 0, fmt.Errorf("array has %d dimensions but %d subscripts given", ndim, len(subscripts))
+	}
+
+	offset := int32(0)
+	for i := 0; i < ndim; i++ {
+		idx := subscripts[i] - arr.Lbs[i]
+		if idx < 0 || idx >= arr.Dims[i] {
+			return 0, fmt.Errorf("array subscript %d out of range [%d:%d]", subscripts[i], arr.Lbs[i], arr.Lbs[i]+arr.Dims[i]-1)
+		}
+		offset = offset*arr.Dims[i] + idx
+	}
+	return offset, nil
+}
+
+// At returns the element at the given subscripts, honoring the lower bounds.
+// nil is returned for NULL elements.
+func (arr *ArrayType) At(subscripts ...int32) (any, error) {
+	offset, err := arr.Offset(subscripts...)
+	if err != nil {
+		return nil, err
+	}
+	return arr.Values[offset], nil
+}
+
+// Nested returns the values as nested []any following Dims, e.g. a 2x3
+// array becomes [][]any{{a, b, c}, {d, e, f}} typed as []any.
+func (arr *ArrayType) Nested() []any {
+	if len(arr.Dims) == 0 {
+		return make([]any, 0)
+	}
+	nested, _ := nestValues(arr.Values, arr.Dims)
+	return nested
+}
+
+func nestValues(values []any, dims []int32) ([]any, []any) {
+	n := int(dims[0])
+	res := make([]any, n)
+	if len(dims) == 1 {
+		copy(res, values[:n])
+		return res, values[n:]
+	}
+
+	for i := 0; i < n; i++ {
+		res[i], values = nestValues(values, dims[1:])
+	}
+	return res, values
 }
 
 func (arr *ArrayType) GetOverHeadNoNulls(ndim int32) uintptr {
@@ -244,6 +376,10 @@ func (arr *ArrayType) IsNull(offset int32) bool {
 }
 
 func (arr *ArrayType) PointerGetArray(ptr uintptr, nitems int32) ([]any, error) {
+	return arr.pointerGetArray(ptr, nitems, false)
+}
+
+func (arr *ArrayType) pointerGetArray(ptr uintptr, nitems int32, nocopy bool) ([]any, error) {
 	var err error = nil
 	values := make([]any, 0)
 	for i := int32(0); i < nitems; i++ {
@@ -253,39 +389,39 @@ func (arr *ArrayType) PointerGetArray(ptr uintptr, nitems int32) ([]any, error)
 			var v any
 			switch arr.Header.Ptyp {
 			case XRG_PTYP_INT8:
-				v, err = PointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(1), arr.Precision, arr.Scale)
+				v, err = pointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(1), arr.Precision, arr.Scale, nocopy)
 				ptr += 1
 				break
 			case XRG_PTYP_INT16:
-				v, err = PointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(2), arr.Precision, arr.Scale)
+				v, err = pointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(2), arr.Precision, arr.Scale, nocopy)
 				ptr += 2
 				break
 			case XRG_PTYP_INT32:
-				v, err = PointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(4), arr.Precision, arr.Scale)
+				v, err = pointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(4), arr.Precision, arr.Scale, nocopy)
 				ptr += 4
 				break
 			case XRG_PTYP_INT64:
-				v, err = PointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(8), arr.Precision, arr.Scale)
+				v, err = pointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(8), arr.Precision, arr.Scale, nocopy)
 				ptr += 8
 				break
 			case XRG_PTYP_INT128:
-				v, err = PointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(16), arr.Precision, arr.Scale)
+				v, err = pointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(16), arr.Precision, arr.Scale, nocopy)
 				ptr += 16
 				break
 			case XRG_PTYP_FP32:
-				v, err = PointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(4), arr.Precision, arr.Scale)
+				v, err = pointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(4), arr.Precision, arr.Scale, nocopy)
 				ptr += 4
 				break
 			case XRG_PTYP_FP64:
-				v, err = PointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(8), arr.Precision, arr.Scale)
+				v, err = pointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(8), arr.Precision, arr.Scale, nocopy)
 				ptr += 8
 				break
 			case XRG_PTYP_BYTEA:
-				v, err = PointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(-1), arr.Precision, arr.Scale)
+				v, err = pointerGetValue(ptr, arr.Header.Ptyp, arr.Header.Ltyp, int16(-1), arr.Precision, arr.Scale, nocopy)
 				ptr += 4 + ByteArrayLen(ptr)
 				break
 			default:
-				err = fmt.Errorf("array element type not supported. ", arr.Header.Ptyp)
+				err = fmt.Errorf("array element type not supported. %d", arr.Header.Ptyp)
 				return nil, err
 			}
 
@@ -306,9 +442,10 @@ type VectorFooter struct {
 }
 
 type Vector struct {
-	Header VectorHeader
-	Data   []byte
-	Flag   []byte
+	Header  VectorHeader
+	Data    []byte
+	Flag    []byte
+	skipped bool
 }
 
 func NewVector(b []byte) (Vector, error) {
@@ -321,20 +458,39 @@ func NewVector(b []byte) (Vector, error) {
 }
 
 func (v *Vector) Read(b []byte) error {
+	return v.ReadAlloc(b, nil)
+}
+
+// ReadAlloc is Read with the buffer for decompressed data obtained from
+// alloc, e.g. a pool. A nil alloc allocates a new buffer.
+func (v *Vector) ReadAlloc(b []byte, alloc func(int) []byte) error {
+	if len(b) < XRG_HEADER_SIZE {
+		return corruptf("vector size %d < header size %d", len(b), XRG_HEADER_SIZE)
+	}
 	err := v.Header.Read(b[0:XRG_HEADER_SIZE])
 	if err != nil {
 		return err
 	}
+	if v.Header.Nbyte < 0 || v.Header.Zbyte < 0 || v.Header.Nitem < 0 {
+		return corruptf("negative size in vector header")
+	}
+	if int64(XRG_HEADER_SIZE)+int64(v.Header.Zbyte)+int64(v.Header.Nitem) > int64(len(b)) {
+		return corruptf("vector size %d < %d + Zbyte %d + Nitem %d", len(b), XRG_HEADER_SIZE, v.Header.Zbyte, v.Header.Nitem)
+	}
+	v.skipped = false
 	if v.Header.Nbyte != v.Header.Zbyte {
-		v.Data = make([]byte, v.Header.Nbyte)
+		if alloc != nil {
+			v.Data = alloc(int(v.Header.Nbyte))
+		} else {
+			v.Data = make([]byte, v.Header.Nbyte)
+		}
 		retsz, err := lz4.UncompressBlock(b[XRG_HEADER_SIZE:XRG_HEADER_SIZE+v.Header.Zbyte], v.Data)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
 
 		if retsz != int(v.Header.Nbyte) {
-			return fmt.Errorf("lz4 uncompress return size != Nbyte ", v.Header.Nbyte)
+			return fmt.Errorf("lz4 uncompress return size %d != Nbyte %d", retsz, v.Header.Nbyte)
 		}
 	} else {
 		v.Data = b[XRG_HEADER_SIZE : XRG_HEADER_SIZE+v.Header.Nbyte]
@@ -343,6 +499,36 @@ func (v *Vector) Read(b []byte) error {
 	return nil
 }
 
+// ReadFlags reads the header and the flags of the vector but not its data,
+// saving the decompression of columns that are not needed. The values of
+// such a vector cannot be read by an Iterator.
+func (v *Vector) ReadFlags(b []byte) error {
+	if len(b) < XRG_HEADER_SIZE {
+		return corruptf("vector size %d < header size %d", len(b), XRG_HEADER_SIZE)
+	}
+	err := v.Header.Read(b[0:XRG_HEADER_SIZE])
+	if err != nil {
+		return err
+	}
+	if v.Header.Nbyte < 0 || v.Header.Zbyte < 0 || v.Header.Nitem < 0 {
+		return corruptf("negative size in vector header")
+	}
+	if int64(XRG_HEADER_SIZE)+int64(v.Header.Zbyte)+int64(v.Header.Nitem) > int64(len(b)) {
+		return corruptf("vector size %d < %d + Zbyte %d + Nitem %d", len(b), XRG_HEADER_SIZE, v.Header.Zbyte, v.Header.Nitem)
+	}
+	v.Data = nil
+	v.Flag = b[XRG_HEADER_SIZE+v.Header.Zbyte : XRG_HEADER_SIZE+v.Header.Zbyte+v.Header.Nitem]
+	v.skipped = true
+	return nil
+}
+
+func (v *Vector) dataPtr() uintptr {
+	if len(v.Data) == 0 {
+		return 0
+	}
+	return uintptr(unsafe.Pointer(&v.Data[0]))
+}
+
 type Iterator struct {
 	Nvec         int
 	Vec          []Vector
@@ -354,6 +540,15 @@ type Iterator struct {
 	Valuesz      []int16
 	Nitem        int32
 	curr         int64
+	err          error
+	names        []string
+	index        map[string]int
+	offsets      [][]int32
+	loaded       []bool
+	proj         []bool
+	lazy         bool
+	nocopy       bool
+	page         *Page
 }
 
 func NewIterator(vec []Vector) Iterator {
@@ -367,20 +562,32 @@ func NewIterator(vec []Vector) Iterator {
 	iter.Value = make([]any, iter.Nvec)
 	iter.Flag = make([]byte, iter.Nvec)
 	iter.Valuesz = make([]int16, iter.Nvec)
+	iter.offsets = make([][]int32, iter.Nvec)
+	iter.loaded = make([]bool, iter.Nvec)
 
 	for i := 0; i < iter.Nvec; i++ {
 		iter.Header[i] = vec[i].Header
-		iter.ValuePtr[i] = uintptr(unsafe.Pointer(&vec[i].Data[0]))
+		iter.ValuePtr[i] = vec[i].dataPtr()
 		iter.Flag[i] = 0
 		iter.NextValuePtr[i] = 0
 		iter.Valuesz[i] = vec[i].Header.Itemsz
 	}
-	iter.Nitem = iter.Header[0].Nitem
+	if iter.Nvec > 0 {
+		iter.Nitem = iter.Header[0].Nitem
+	}
 
 	return iter
 }
 
+// PointerGetValue decodes the value at ptr. The result never aliases the
+// page: strings, I128 and arrays are copied.
 func PointerGetValue(ptr uintptr, ptyp PhysicalType, ltyp LogicalType, itemsz int16, precision int16, scale int16) (any, error) {
+	return pointerGetValue(ptr, ptyp, ltyp, itemsz, precision, scale, false)
+}
+
+// pointerGetValue is PointerGetValue where nocopy makes strings, I128 and
+// arrays alias the page instead of copying them.
+func pointerGetValue(ptr uintptr, ptyp PhysicalType, ltyp LogicalType, itemsz int16, precision int16, scale int16, nocopy bool) (any, error) {
 	var err error = nil
 	if itemsz > 0 {
 		switch ptyp {
@@ -403,6 +610,9 @@ func PointerGetValue(ptr uintptr, ptyp PhysicalType, ltyp LogicalType, itemsz in
 			} else {
 				p := (*uint64)(unsafe.Pointer(ptr))
 				i128 := unsafe.Slice(p, 2)
+				if !nocopy {
+					i128 = []uint64{i128[0], i128[1]}
+				}
 				return I128{i128}, err
 			}
 		case XRG_PTYP_FP32:
@@ -412,17 +622,27 @@ func PointerGetValue(ptr uintptr, ptyp PhysicalType, ltyp LogicalType, itemsz in
 			fp64 := *(*float64)(unsafe.Pointer(ptr))
 			return fp64, err
 		default:
-			err = fmt.Errorf("unknown type", ptyp)
+			err = fmt.Errorf("unknown type %d", ptyp)
 			break
 		}
 	} else {
 		dataptr := ByteArrayPtr(ptr)
 		sz := ByteArrayLen(ptr)
 		if ltyp == XRG_LTYP_STRING {
-			s := string(unsafe.Slice((*byte)(unsafe.Pointer(dataptr)), sz))
-			return s, err
+			b := unsafe.Slice((*byte)(unsafe.Pointer(dataptr)), sz)
+			if nocopy {
+				return unsafeString(b), err
+			}
+			return string(b), err
+		} else if sz == 0 {
+			// NULL arrays may be stored as empty bytea
+			var arr ArrayType
+			arr.Precision = precision
+			arr.Scale = scale
+			arr.Values = make([]any, 0)
+			return arr, err
 		} else {
-			arr, err := NewArrayType(dataptr, precision, scale)
+			arr, err := newArrayType(dataptr, precision, scale, nocopy)
 			if err != nil {
 				return nil, err
 			}
@@ -433,8 +653,63 @@ func PointerGetValue(ptr uintptr, ptyp PhysicalType, ltyp LogicalType, itemsz in
 	return nil, err
 }
 
+// SetColumns names the columns of the iterator, one name per vector.
+func (iter *Iterator) SetColumns(names []string) error {
+	if len(names) != iter.Nvec {
+		return fmt.Errorf("%d column names for %d vectors", len(names), iter.Nvec)
+	}
+
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := index[name]; ok {
+			return fmt.Errorf("duplicate column name %s", name)
+		}
+		index[name] = i
+	}
+	iter.names = names
+	iter.index = index
+	return nil
+}
+
+// Columns returns the column names set by SetColumns.
+func (iter *Iterator) Columns() []string {
+	return iter.names
+}
+
+// Index returns the position of the named column or -1 if not found.
+func (iter *Iterator) Index(name string) int {
+	i, ok := iter.index[name]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+// Get returns the value of the named column in the current row. nil is
+// returned for NULL.
+func (iter *Iterator) Get(name string) (any, error) {
+	i := iter.Index(name)
+	if i < 0 {
+		return nil, fmt.Errorf("column %s not found", name)
+	}
+	if iter.Flag[i]&XRG_FLAG_NULL != 0 {
+		return nil, nil
+	}
+	return iter.Column(i)
+}
+
+// Err returns the error, if any, that stopped Next. Next returning false
+// with a nil Err means the end of the page was reached.
+func (iter *Iterator) Err() error {
+	return iter.err
+}
+
 func (iter *Iterator) Next() bool {
 	var err error = nil
+	if iter.err != nil {
+		return false
+	}
+
 	inval := byte(1)
 	for inval != 0 {
 		curr := iter.curr + 1
@@ -447,46 +722,199 @@ func (iter *Iterator) Next() bool {
 
 		if 0 == curr {
 			for i := 0; i < iter.Nvec; i++ {
-				iter.ValuePtr[i] = uintptr(unsafe.Pointer(&iter.Vec[i].Data[0]))
-				iter.NextValuePtr[i] = uintptr(unsafe.Pointer(&iter.Vec[i].Data[0]))
-				iter.Value[i], err = PointerGetValue(iter.ValuePtr[i], iter.Header[i].Ptyp, iter.Header[i].Ltyp, iter.Header[i].Itemsz, iter.Header[i].Precision, iter.Header[i].Scale)
-				if err != nil {
-					fmt.Println(err)
-					return false
-				}
-				iter.Flag[i] = iter.Vec[i].Flag[curr]
-				inval |= iter.Flag[i] & XRG_FLAG_INVAL
-
-				itemsz := iter.Header[i].Itemsz
-				if itemsz > 0 {
-					iter.NextValuePtr[i] = iter.NextValuePtr[i] + uintptr(itemsz)
-				} else {
-					iter.NextValuePtr[i] = ByteArrayPtr(iter.NextValuePtr[i]) + ByteArrayLen(iter.NextValuePtr[i])
-				}
+				iter.NextValuePtr[i] = iter.Vec[i].dataPtr()
 			}
-		} else {
-			for i := 0; i < iter.Nvec; i++ {
-				// advance the flag
-				iter.Flag[i] = iter.Vec[i].Flag[curr]
-				inval |= iter.Flag[i] & XRG_FLAG_INVAL
+		}
+
+		for i := 0; i < iter.Nvec; i++ {
+			// advance the flag
+			iter.Flag[i] = iter.Vec[i].Flag[curr]
+			inval |= iter.Flag[i] & XRG_FLAG_INVAL
 
-				iter.ValuePtr[i] = iter.NextValuePtr[i]
-				iter.Value[i], err = PointerGetValue(iter.ValuePtr[i], iter.Header[i].Ptyp, iter.Header[i].Ltyp, iter.Header[i].Itemsz, iter.Header[i].Precision, iter.Header[i].Scale)
+			iter.Value[i] = nil
+			iter.loaded[i] = false
+			if iter.Vec[i].skipped {
+				continue
+			}
+
+			iter.ValuePtr[i] = iter.NextValuePtr[i]
+			if !iter.lazy && (iter.proj == nil || iter.proj[i]) {
+				iter.Value[i], err = iter.getValue(i)
 				if err != nil {
-					fmt.Println(err)
+					iter.err = err
 					return false
 				}
+				iter.loaded[i] = true
+			}
 
-				// advance the value
-				itemsz := iter.Header[i].Itemsz
-				if itemsz > 0 {
-					iter.NextValuePtr[i] = iter.NextValuePtr[i] + uintptr(itemsz)
-				} else {
-					iter.NextValuePtr[i] = ByteArrayPtr(iter.NextValuePtr[i]) + ByteArrayLen(iter.NextValuePtr[i])
-				}
+			// advance the value
+			itemsz := iter.Header[i].Itemsz
+			if itemsz > 0 {
+				iter.NextValuePtr[i] = iter.NextValuePtr[i] + uintptr(itemsz)
+			} else {
+				iter.NextValuePtr[i] = ByteArrayPtr(iter.NextValuePtr[i]) + ByteArrayLen(iter.NextValuePtr[i])
 			}
 		}
 
 	}
 	return true
 }
+
+func (iter *Iterator) getValue(i int) (any, error) {
+	hdr := &iter.Header[i]
+	return pointerGetValue(iter.ValuePtr[i], hdr.Ptyp, hdr.Ltyp, hdr.Itemsz, hdr.Precision, hdr.Scale, iter.nocopy)
+}
+
+// SetZeroCopy makes strings, I128 and arrays alias the page instead of
+// being copied. Such values, like those returned by Bytes, are only valid
+// until the page is released.
+func (iter *Iterator) SetZeroCopy(nocopy bool) {
+	iter.nocopy = nocopy
+}
+
+// Page returns the page the iterator was created from, nil if it was
+// created by NewIterator.
+func (iter *Iterator) Page() *Page {
+	return iter.page
+}
+
+// Bytes returns the raw bytes of the bytea column i in the current row.
+// The slice aliases the page and must not be modified.
+func (iter *Iterator) Bytes(i int) ([]byte, error) {
+	if i < 0 || i >= iter.Nvec {
+		return nil, fmt.Errorf("column %d out of range [0, %d)", i, iter.Nvec)
+	}
+	if iter.curr < 0 || iter.curr >= int64(iter.Nitem) {
+		return nil, fmt.Errorf("iterator is not positioned on a row")
+	}
+	if iter.Header[i].Itemsz > 0 {
+		return nil, fmt.Errorf("column %d is not bytea", i)
+	}
+	if iter.Vec[i].skipped {
+		return nil, fmt.Errorf("column %d was not read", i)
+	}
+
+	ptr := iter.ValuePtr[i]
+	sz := ByteArrayLen(ptr)
+	if sz == 0 {
+		return []byte{}, nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(ByteArrayPtr(ptr))), sz), nil
+}
+
+// SetLazy stops Next from decoding values. Values are decoded on the first
+// Column or Get of each column in the current row.
+func (iter *Iterator) SetLazy(lazy bool) {
+	iter.lazy = lazy
+}
+
+// SetProjection restricts the columns decoded by Next to cols. Value holds
+// nil for the other columns; they can still be decoded with Column. A nil
+// cols decodes every column.
+func (iter *Iterator) SetProjection(cols []int) error {
+	if cols == nil {
+		iter.proj = nil
+		return nil
+	}
+
+	proj := make([]bool, iter.Nvec)
+	for _, i := range cols {
+		if i < 0 || i >= iter.Nvec {
+			return fmt.Errorf("projected column %d out of range [0, %d)", i, iter.Nvec)
+		}
+		proj[i] = true
+	}
+	iter.proj = proj
+	return nil
+}
+
+// Column returns the value of column i in the current row, decoding it if
+// Next did not. The value of a NULL column is decoded as zero.
+func (iter *Iterator) Column(i int) (any, error) {
+	if i < 0 || i >= iter.Nvec {
+		return nil, fmt.Errorf("column %d out of range [0, %d)", i, iter.Nvec)
+	}
+	if iter.curr < 0 || iter.curr >= int64(iter.Nitem) {
+		return nil, fmt.Errorf("iterator is not positioned on a row")
+	}
+	if iter.loaded[i] {
+		return iter.Value[i], nil
+	}
+	if iter.Vec[i].skipped {
+		return nil, fmt.Errorf("column %d was not read", i)
+	}
+
+	v, err := iter.getValue(i)
+	if err != nil {
+		return nil, err
+	}
+	iter.Value[i] = v
+	iter.loaded[i] = true
+	return v, nil
+}
+
+// Row returns the index of the current row, -1 before the first Next.
+func (iter *Iterator) Row() int {
+	return int(iter.curr)
+}
+
+// Reset rewinds the iterator to before the first row.
+func (iter *Iterator) Reset() {
+	iter.curr = -1
+	iter.err = nil
+}
+
+// Seek positions the iterator so that the following Next returns row (or
+// the first valid row after it). Seeking to Nitem moves to the end.
+func (iter *Iterator) Seek(row int) error {
+	if row < 0 || row > int(iter.Nitem) {
+		return fmt.Errorf("seek to row %d out of range [0, %d]", row, iter.Nitem)
+	}
+
+	iter.err = nil
+	if row == 0 || row == int(iter.Nitem) {
+		iter.curr = int64(row) - 1
+		return nil
+	}
+
+	for i := 0; i < iter.Nvec; i++ {
+		if !iter.Vec[i].skipped {
+			iter.NextValuePtr[i] = iter.valuePtrAt(i, row)
+		}
+	}
+	iter.curr = int64(row) - 1
+	return nil
+}
+
+// Skip skips the next n rows.
+func (iter *Iterator) Skip(n int) error {
+	if n < 0 {
+		return fmt.Errorf("skip %d rows", n)
+	}
+	row := int(iter.curr) + 1 + n
+	if row > int(iter.Nitem) {
+		row = int(iter.Nitem)
+	}
+	return iter.Seek(row)
+}
+
+// valuePtrAt returns the address of the value of vector i in row. Variable
+// length vectors are located through an offset index built on first use.
+func (iter *Iterator) valuePtrAt(i int, row int) uintptr {
+	base := iter.Vec[i].dataPtr()
+	itemsz := iter.Header[i].Itemsz
+	if itemsz > 0 {
+		return base + uintptr(row)*uintptr(itemsz)
+	}
+
+	if iter.offsets[i] == nil {
+		offsets := make([]int32, iter.Nitem)
+		ptr := base
+		for k := range offsets {
+			offsets[k] = int32(ptr - base)
+			ptr = ByteArrayPtr(ptr) + ByteArrayLen(ptr)
+		}
+		iter.offsets[i] = offsets
+	}
+	return base + uintptr(iter.offsets[i][row])
+}
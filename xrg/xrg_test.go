@@ -0,0 +1,124 @@
+package xrg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testIterator returns an iterator over the vectors of the test file.
+func testIterator(t *testing.T) Iterator {
+	t.Helper()
+	var vec []Vector
+	for _, b := range rawVectors(t) {
+		v, err := NewVector(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vec = append(vec, v)
+	}
+	return NewIterator(vec)
+}
+
+// rowValues returns the values of the current row, nil for NULL.
+func rowValues(t *testing.T, it *Iterator) []any {
+	t.Helper()
+	row := make([]any, it.Nvec)
+	for i := range row {
+		if it.Flag[i]&XRG_FLAG_NULL != 0 {
+			continue
+		}
+		v, err := it.Column(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row[i] = v
+	}
+	return row
+}
+
+func TestIteratorSeek(t *testing.T) {
+	it := testIterator(t)
+	var rows [][]any
+	for it.Next() {
+		if it.Row() != len(rows) {
+			t.Fatalf("row %d after %d rows", it.Row(), len(rows))
+		}
+		rows = append(rows, rowValues(t, &it))
+	}
+	n := int(it.Nitem)
+	if len(rows) != n || n < 4 {
+		t.Fatalf("scanned %d of %d rows", len(rows), n)
+	}
+
+	// seek both from the end of a full scan and from the middle of one
+	for _, k := range []int{0, 1, n / 2, n - 1, n, n - 1, n / 2, 1, 0} {
+		if err := it.Seek(k); err != nil {
+			t.Fatalf("Seek(%d): %v", k, err)
+		}
+		if k == n {
+			if it.Next() {
+				t.Errorf("Seek(%d): Next returned row %d", k, it.Row())
+			}
+			continue
+		}
+		if !it.Next() {
+			t.Fatalf("Seek(%d): Next returned false", k)
+		}
+		if it.Row() != k {
+			t.Errorf("Seek(%d): at row %d", k, it.Row())
+		}
+		if got := rowValues(t, &it); !reflect.DeepEqual(got, rows[k]) {
+			t.Errorf("Seek(%d): got %v, want %v", k, got, rows[k])
+		}
+		if k+1 < n {
+			if !it.Next() {
+				t.Fatalf("Seek(%d): second Next returned false", k)
+			}
+			if got := rowValues(t, &it); !reflect.DeepEqual(got, rows[k+1]) {
+				t.Errorf("Seek(%d): next row got %v, want %v", k, got, rows[k+1])
+			}
+		}
+	}
+
+	if err := it.Seek(-1); err == nil {
+		t.Error("Seek(-1): expected error")
+	}
+	if err := it.Seek(n + 1); err == nil {
+		t.Errorf("Seek(%d): expected error", n+1)
+	}
+}
+
+func TestIteratorSkipReset(t *testing.T) {
+	it := testIterator(t)
+	n := int(it.Nitem)
+	it.Next()
+	first := rowValues(t, &it)
+
+	if err := it.Skip(2); err != nil {
+		t.Fatal(err)
+	}
+	if !it.Next() || it.Row() != 3 {
+		t.Fatalf("Skip(2) from row 0: at row %d", it.Row())
+	}
+
+	it.Reset()
+	if it.Row() != -1 {
+		t.Errorf("Reset: at row %d", it.Row())
+	}
+	if !it.Next() || it.Row() != 0 {
+		t.Fatalf("Reset: Next at row %d", it.Row())
+	}
+	if got := rowValues(t, &it); !reflect.DeepEqual(got, first) {
+		t.Errorf("Reset: got %v, want %v", got, first)
+	}
+
+	if err := it.Skip(n + 10); err != nil {
+		t.Fatal(err)
+	}
+	if it.Next() {
+		t.Errorf("Skip past the end: Next returned row %d", it.Row())
+	}
+	if err := it.Skip(-1); err == nil {
+		t.Error("Skip(-1): expected error")
+	}
+}